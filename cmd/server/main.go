@@ -3,41 +3,155 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/cache"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/couriers"
 	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/eta"
+	"github.com/rajnish-012/delivery-management-system/internal/health"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+	"github.com/rajnish-012/delivery-management-system/internal/orders"
+	"github.com/rajnish-012/delivery-management-system/internal/privacy"
+	"github.com/rajnish-012/delivery-management-system/internal/ratelimit"
+	"github.com/rajnish-012/delivery-management-system/internal/reports"
+	"github.com/rajnish-012/delivery-management-system/internal/tracing"
+	"github.com/rajnish-012/delivery-management-system/migrations"
 )
 
+// fatal logs err and exits; used for startup failures before the server is running
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// registerReadinessChecks wires the dependencies /readyz reports on
+func registerReadinessChecks(hc config.HealthConfig) error {
+	expected, err := database.LatestMigration(migrations.FS)
+	if err != nil {
+		return err
+	}
+	health.Register(health.Check{Name: "postgres", Timeout: hc.CheckTimeout, Fn: database.PingPostgres})
+	health.Register(health.Check{Name: "redis", Timeout: hc.CheckTimeout, Fn: database.PingRedis})
+	health.Register(health.Check{Name: "migrations", Timeout: hc.CheckTimeout, Fn: func(ctx context.Context) error {
+		v, err := database.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if v < expected {
+			return fmt.Errorf("schema version %d, want %d", v, expected)
+		}
+		return nil
+	}})
+	health.Register(health.Check{Name: "progression_worker", Timeout: hc.CheckTimeout, Fn: func(context.Context) error {
+		age := orders.HeartbeatAge()
+		if age < 0 || age > 3*hc.HeartbeatInterval {
+			return fmt.Errorf("a progression worker last beat %s ago", age.Round(time.Second))
+		}
+		return nil
+	}})
+	return nil
+}
+
 func main() {
-	ctx := context.Background()
+	// SIGTERM is what container orchestrators send; SIGINT for local runs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+
+	// Initialize logging
+	if _, err := logging.Init(os.Stdout, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fatal("logging init failed", err)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		fatal("tracing init failed", err)
+	}
+	defer func() {
+		ctxFlush, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctxFlush); err != nil {
+			slog.Error("tracing shutdown failed", "error", err)
+		}
+	}()
+
+	// bg owns background workers and closes the connection pools after they drain
+	bg := background.New()
+	auth.Configure(cfg.Auth)
+	orders.Configure(cfg.Orders, bg)
+	ratelimit.Configure(cfg.Limits)
+	reports.Configure(cfg.Reports)
+	cache.Configure(cfg.Cache)
+	notify.Configure(cfg.Notify, bg)
+	privacy.Configure(cfg.Retention, bg)
+	couriers.Configure(cfg.Routing, bg)
+	eta.Configure(cfg.ETA)
 
 	// Initialize PostgreSQL
-	if err := database.InitPostgres(ctx); err != nil {
-		log.Fatalf("postgres init failed: %v", err)
+	if err := database.InitPostgres(ctx, cfg.Postgres); err != nil {
+		fatal("postgres init failed", err)
 	}
-	defer database.ClosePostgres()
+	bg.OnClose("postgres", database.ClosePostgres)
 
 	// Initialize Redis
-	if err := database.InitRedis(ctx); err != nil {
-		log.Fatalf("redis init failed: %v", err)
+	if err := database.InitRedis(ctx, cfg.Redis); err != nil {
+		fatal("redis init failed", err)
 	}
-	defer database.CloseRedis()
+	bg.OnClose("redis", database.CloseRedis)
 
-	//FIXED MIGRATION LOADING — PROPER IMPLEMENTATION
-	migrationContent, err := os.ReadFile("migrations/0001_init.sql")
+	// Apply schema migrations
+	version, err := database.Migrate(ctx, migrations.FS)
 	if err != nil {
-		log.Fatalf("failed to read migration file: %v", err)
+		fatal("migration failed", err)
+	}
+	slog.Info("database initialized and migrations applied", "schema_version", version)
+
+	if a := cfg.Auth; a.AdminUsername != "" {
+		created, err := models.EnsureUser(database.WithTenant(ctx, models.OperatorTenantID), a.AdminUsername, a.AdminPassword, "admin")
+		if err != nil {
+			fatal("admin bootstrap failed", err)
+		}
+		if created {
+			slog.Info("created operator admin", "username", a.AdminUsername)
+		}
+	}
+
+	orders.ConfigureHeartbeat(cfg.Health.HeartbeatInterval)
+	if err := notify.Start(); err != nil {
+		fatal("notification workers start failed", err)
+	}
+	if err := auth.StartPasswordResets(bg); err != nil {
+		fatal("password reset worker start failed", err)
+	}
+	if err := privacy.Start(); err != nil {
+		fatal("retention job start failed", err)
+	}
+	if err := registerReadinessChecks(cfg.Health); err != nil {
+		fatal("readiness setup failed", err)
 	}
-	if err := database.ExecMigration(ctx, string(migrationContent)); err != nil {
-		log.Fatalf("migration failed: %v", err)
+	if n, err := orders.ResumeProgressions(ctx); err != nil {
+		slog.Error("failed to resume order progressions", "error", err)
+	} else if n > 0 {
+		slog.Info("resumed order progressions", "count", n)
 	}
-	fmt.Println("Database initialized and migration applied successfully!")
 
 	// Setup HTTP router
 	r := mux.NewRouter()
@@ -45,35 +159,46 @@ func main() {
 
 	// Setup server configuration
 	srv := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.HTTP.Addr,
 		Handler:      r,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
 	}
 
 	// Start server in Goroutine
+	serveErr := make(chan error, 1)
 	go func() {
-		fmt.Println("Server running on http://localhost:8080")
-		if err := srv.ListenAndServe(); err != nil {
-			if err == http.ErrServerClosed {
-				fmt.Println("Server stopped gracefully")
-			} else {
-				log.Fatalf("Server failed: %v", err)
-			}
+		slog.Info("server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
 		}
 	}()
 
 	// Graceful Shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received")
+	case err := <-serveErr:
+		slog.Error("server failed", "error", err)
+	}
+	stop()
+
+	// fail readiness first so the load balancer stops sending new requests
+	health.SetShuttingDown()
 
-	fmt.Println("\nShutting down server...")
-	ctxShut, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// stop accepting HTTP work and let in-flight requests finish
+	ctxShut, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctxShut); err != nil {
-		log.Fatalf("Server shutdown failed: %v", err)
+		slog.Error("http shutdown incomplete", "error", err)
+	}
+
+	// drain progression goroutines, then close Redis and Postgres
+	ctxDrain, cancelDrain := context.WithTimeout(context.Background(), cfg.Orders.DrainTimeout)
+	defer cancelDrain()
+	if err := bg.Shutdown(ctxDrain); err != nil {
+		slog.Error("background workers did not drain in time", "error", err)
 	}
-	fmt.Println("Server exited properly")
+	slog.Info("server exited properly")
 }
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/eta"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/orders"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// maxBatchBytes caps the body of a batch import, JSON or CSV
+const maxBatchBytes = 10 << 20
+
+// batchRow is one input row with the errors found while parsing or validating it
+type batchRow struct {
+	req  createOrderReq
+	errs validate.Errors
+}
+
+// batchResult reports what happened to one input row; Row is 1-based and does
+// not count a CSV header
+type batchResult struct {
+	Row    int                   `json:"row"`
+	Status string                `json:"status"` // created or failed
+	Order  *models.Order         `json:"order,omitempty"`
+	Errors []validate.FieldError `json:"errors,omitempty"`
+}
+
+type batchResponse struct {
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []batchResult `json:"results"`
+}
+
+// batchCreateOrdersHandler creates many orders from a JSON array, a text/csv body or a
+// multipart upload with a CSV "file" part. Rows are validated independently: valid
+// rows are created together, invalid ones are reported and skipped.
+func batchCreateOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getClaims(r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	if !requireScope(w, claims, auth.ScopeOrdersWrite) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+	rows, err := readBatch(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(rows) == 0 {
+		writeRequestError(w, validate.Errors{{Field: "body", Message: "must contain at least one order"}})
+		return
+	}
+	if max := orders.BatchMaxRows(); len(rows) > max {
+		writeRequestError(w, validate.Errors{{Field: "body", Message: fmt.Sprintf("must contain at most %d orders, got %d", max, len(rows))}})
+		return
+	}
+
+	customers := make([]int, len(rows))
+	var lookup []int
+	for i := range rows {
+		row := &rows[i]
+		if row.errs != nil {
+			continue
+		}
+		if err := validate.Struct(&row.req); err != nil {
+			var verrs validate.Errors
+			errors.As(err, &verrs)
+			row.errs = verrs
+			continue
+		}
+		if errs := row.req.check(); errs != nil {
+			row.errs = errs
+			continue
+		}
+		id, ferr := orderCustomer(claims, row.req.CustomerID)
+		if ferr != nil {
+			row.errs = validate.Errors{*ferr}
+			continue
+		}
+		customers[i] = id
+		if claims.Role == auth.RoleMerchant {
+			lookup = append(lookup, id)
+		}
+	}
+	if len(lookup) > 0 {
+		known, err := models.ExistingUserIDs(r.Context(), lookup)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		for i := range rows {
+			if rows[i].errs == nil && !known[customers[i]] {
+				rows[i].errs = validate.Errors{unknownCustomer}
+			}
+		}
+	}
+
+	// zones are loaded once, and only if some row is still worth checking
+	var zones []*models.Zone
+	zoneIDs := make([]*int, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.errs != nil {
+			continue
+		}
+		if zones == nil {
+			if zones, err = models.ListZones(r.Context()); err != nil {
+				internalError(w, r, err)
+				return
+			}
+			if zones == nil {
+				zones = []*models.Zone{}
+			}
+		}
+		zoneIDs[i], row.errs = locateOrder(zones, row.req.Pickup, row.req.Dropoff)
+	}
+
+	var valid []models.NewOrder
+	for i, row := range rows {
+		if row.errs == nil {
+			valid = append(valid, row.req.newOrder(customers[i], zoneIDs[i]))
+		}
+	}
+	var created []*models.Order
+	if len(valid) > 0 {
+		created, err = models.CreateOrders(r.Context(), valid)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		eta.Refresh(r.Context(), created...)
+	}
+
+	resp := batchResponse{Results: make([]batchResult, len(rows))}
+	ids := make([]int, 0, len(created))
+	next := 0
+	for i, row := range rows {
+		res := batchResult{Row: i + 1}
+		if row.errs != nil {
+			res.Status = "failed"
+			res.Errors = row.errs
+			resp.Failed++
+		} else {
+			res.Status = "created"
+			res.Order = created[next]
+			ids = append(ids, created[next].ID)
+			metrics.ObserveTransition("none", created[next].Status)
+			next++
+			resp.Created++
+		}
+		resp.Results[i] = res
+	}
+	if err := orders.QueueProgressions(r.Context(), ids); err != nil {
+		// shutting down: the orders exist and ResumeProgressions picks them up on restart
+		logging.FromContext(r.Context()).Warn("batch progressions not queued", "orders", len(ids), "error", err)
+	}
+	writeJSON(w, resp, http.StatusOK)
+}
+
+// readBatch parses the request body into rows according to its content type.
+func readBatch(r *http.Request) ([]batchRow, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+	switch mediaType {
+	case "application/json":
+		return readBatchJSON(r.Body)
+	case "text/csv":
+		return readBatchCSV(r.Body)
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, validate.Errors{{Field: "body", Message: err.Error()}}
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, validate.Errors{{Field: "file", Message: "is required"}}
+			}
+			if err != nil {
+				return nil, decodeError(err)
+			}
+			if part.FormName() == "file" {
+				return readBatchCSV(part)
+			}
+		}
+	}
+	return nil, validate.Errors{{Field: "Content-Type", Message: "must be application/json, text/csv or multipart/form-data"}}
+}
+
+// readBatchJSON reads a JSON array of createOrderReq objects. Each element is
+// decoded on its own so one bad row doesn't reject the whole batch.
+func readBatchJSON(body io.Reader) ([]batchRow, error) {
+	var raw []json.RawMessage
+	dec := json.NewDecoder(body)
+	if err := dec.Decode(&raw); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field == "" {
+			return nil, validate.Errors{{Field: "body", Message: "must be a JSON array of orders"}}
+		}
+		return nil, decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return nil, validate.Errors{{Field: "body", Message: "must contain a single JSON array"}}
+	}
+	rows := make([]batchRow, len(raw))
+	for i, m := range raw {
+		d := json.NewDecoder(bytes.NewReader(m))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&rows[i].req); err != nil {
+			var verrs validate.Errors
+			if !errors.As(decodeError(err), &verrs) {
+				verrs = validate.Errors{{Field: "body", Message: err.Error()}}
+			}
+			rows[i].errs = verrs
+		}
+	}
+	return rows, nil
+}
+
+// csvColumns are the columns a batch CSV may have
+var csvColumns = []string{"item", "customer_id", "pickup_lat", "pickup_lng", "dropoff_lat", "dropoff_lng", "deliver_after", "deliver_before"}
+
+// readBatchCSV reads CSV with a header row naming the columns: item (required),
+// customer_id (for merchant API keys), the pickup and drop-off positions and the
+// delivery window.
+func readBatchCSV(body io.Reader) ([]batchRow, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1 // row width is checked per row below
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, validate.Errors{{Field: "body", Message: "must start with a header row"}}
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(h))
+		if !contains(csvColumns, name) {
+			return nil, validate.Errors{{Field: "header", Message: fmt.Sprintf("unknown column %q", h)}}
+		}
+		cols[name] = i
+	}
+	if _, ok := cols["item"]; !ok {
+		return nil, validate.Errors{{Field: "header", Message: `must include an "item" column`}}
+	}
+
+	var rows []batchRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		var row batchRow
+		if len(rec) != len(header) {
+			row.errs = validate.Errors{{Field: "row", Message: fmt.Sprintf("has %d fields, header has %d", len(rec), len(header))}}
+			rows = append(rows, row)
+			continue
+		}
+		row.req.Item = rec[cols["item"]]
+		if i, ok := cols["customer_id"]; ok && strings.TrimSpace(rec[i]) != "" {
+			id, err := strconv.Atoi(strings.TrimSpace(rec[i]))
+			if err != nil {
+				row.errs = validate.Errors{{Field: "customer_id", Message: "must be of type int"}}
+			}
+			row.req.CustomerID = id
+		}
+		var ferr *validate.FieldError
+		if row.req.Pickup, ferr = csvPoint(cols, rec, "pickup"); ferr != nil && row.errs == nil {
+			row.errs = validate.Errors{*ferr}
+		}
+		if row.req.Dropoff, ferr = csvPoint(cols, rec, "dropoff"); ferr != nil && row.errs == nil {
+			row.errs = validate.Errors{*ferr}
+		}
+		if row.req.DeliverAfter, ferr = csvTime(cols, rec, "deliver_after"); ferr != nil && row.errs == nil {
+			row.errs = validate.Errors{*ferr}
+		}
+		if row.req.DeliverBefore, ferr = csvTime(cols, rec, "deliver_before"); ferr != nil && row.errs == nil {
+			row.errs = validate.Errors{*ferr}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvPoint reads the <name>_lat and <name>_lng columns of rec. Both empty or
+// absent means no position.
+func csvPoint(cols map[string]int, rec []string, name string) (*geo.Point, *validate.FieldError) {
+	var vals [2]string
+	for k, suffix := range []string{"_lat", "_lng"} {
+		if i, ok := cols[name+suffix]; ok {
+			vals[k] = strings.TrimSpace(rec[i])
+		}
+	}
+	if vals[0] == "" && vals[1] == "" {
+		return nil, nil
+	}
+	lat, err1 := strconv.ParseFloat(vals[0], 64)
+	lng, err2 := strconv.ParseFloat(vals[1], 64)
+	if err1 != nil || err2 != nil {
+		return nil, &validate.FieldError{Field: name, Message: "needs numeric " + name + "_lat and " + name + "_lng"}
+	}
+	return &geo.Point{Lat: lat, Lng: lng}, nil
+}
+
+// csvTime reads an RFC 3339 timestamp from the named column of rec. Empty or
+// absent means none.
+func csvTime(cols map[string]int, rec []string, name string) (*time.Time, *validate.FieldError) {
+	i, ok := cols[name]
+	if !ok || strings.TrimSpace(rec[i]) == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(rec[i]))
+	if err != nil {
+		return nil, &validate.FieldError{Field: name, Message: "must be an RFC 3339 timestamp"}
+	}
+	return &t, nil
+}
+
+func csvError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errBodyTooLarge
+	}
+	return validate.Errors{{Field: "body", Message: "is not valid CSV: " + err.Error()}}
+}
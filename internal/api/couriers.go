@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/couriers"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// requireCourier returns the caller's claims if they are a courier signed in as
+// themselves, and replies 403 otherwise
+func requireCourier(w http.ResponseWriter, r *http.Request) *auth.Claims {
+	claims, err := getClaims(r)
+	if err != nil || claims.KeyID != 0 || claims.Role != "courier" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil
+	}
+	return claims
+}
+
+// courierFound reports whether a courier lookup succeeded, replying 404 or 500 if not
+func courierFound(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "courier not found", http.StatusNotFound)
+	default:
+		internalError(w, r, err)
+	}
+	return false
+}
+
+// courierRouteHandler returns the caller's planned route and last reported
+// position. The route is replanned whenever their assignments change.
+func courierRouteHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireCourier(w, r)
+	if claims == nil {
+		return
+	}
+	c, err := models.GetCourier(r.Context(), claims.UserID)
+	if !courierFound(w, r, err) {
+		return
+	}
+	writeJSON(w, c, http.StatusOK)
+}
+
+type positionReq struct {
+	Lat *float64 `json:"lat" validate:"required"`
+	Lng *float64 `json:"lng" validate:"required"`
+}
+
+// courierPositionHandler records where the caller is. Routes are planned, and
+// ETAs estimated, from the last reported position.
+func courierPositionHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireCourier(w, r)
+	if claims == nil {
+		return
+	}
+	var req positionReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	p := geo.Point{Lat: *req.Lat, Lng: *req.Lng}
+	if !p.Valid() {
+		writeRequestError(w, validate.Errors{{Field: "body", Message: invalidPosition}})
+		return
+	}
+	if err := models.SetCourierPosition(r.Context(), claims.UserID, p); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	couriers.Moved(r.Context(), claims.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func adminListCouriersHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	list, err := models.ListCouriers(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if list == nil {
+		list = []*models.Courier{}
+	}
+	writeJSON(w, list, http.StatusOK)
+}
+
+func adminGetCourierHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	c, err := models.GetCourier(r.Context(), id)
+	if !courierFound(w, r, err) {
+		return
+	}
+	writeJSON(w, c, http.StatusOK)
+}
+
+type assignCourierReq struct {
+	// CourierID is null to take the order off its courier
+	CourierID *int `json:"courier_id"`
+}
+
+// adminAssignCourierHandler assigns an order to a courier, or unassigns it, and
+// replans the routes of the couriers involved.
+func adminAssignCourierHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireAdmin(w, r)
+	if claims == nil {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	var req assignCourierReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if req.CourierID != nil && *req.CourierID < 1 {
+		writeRequestError(w, validate.Errors{{Field: "courier_id", Message: "must be at least 1"}})
+		return
+	}
+	o, err := couriers.Assign(r.Context(), id, req.CourierID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "order not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrNotCourier):
+		writeRequestError(w, validate.Errors{{Field: "courier_id", Message: "is not an active courier"}})
+		return
+	case errors.Is(err, models.ErrOrderFinished), errors.Is(err, models.ErrNoPositions), errors.Is(err, models.ErrCourierFull):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		internalError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("order courier assigned", "order_id", o.ID, "courier_id", optionalID(o.CourierID), "by", claims.UserID)
+	writeJSON(w, o, http.StatusOK)
+}
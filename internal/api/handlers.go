@@ -3,29 +3,94 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/eta"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/health"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
 	"github.com/rajnish-012/delivery-management-system/internal/models"
 	"github.com/rajnish-012/delivery-management-system/internal/orders"
+	"github.com/rajnish-012/delivery-management-system/internal/ratelimit"
+	"github.com/rajnish-012/delivery-management-system/internal/tracing"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
 )
 
 func RegisterRoutes(r *mux.Router) {
+	r.Use(tracing.Middleware, logging.Middleware, metrics.Middleware)
 	r.HandleFunc("/health", healthHandler).Methods("GET")
-	r.HandleFunc("/register", registerHandler).Methods("POST")
-	r.HandleFunc("/login", loginHandler).Methods("POST")
+	r.HandleFunc("/livez", health.LiveHandler).Methods("GET")
+	r.HandleFunc("/readyz", health.ReadyHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", openapiHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	// auth routes are throttled per client IP and per username
+	authLimit := ratelimit.Middleware("auth", ratelimit.IP, usernameKey)
+	r.Handle("/register", authLimit(http.HandlerFunc(registerHandler))).Methods("POST")
+	r.Handle("/login", authLimit(http.HandlerFunc(loginHandler))).Methods("POST")
+	r.Handle("/login/2fa", authLimit(http.HandlerFunc(loginTwoFactorHandler))).Methods("POST")
+	r.Handle("/password-reset", authLimit(http.HandlerFunc(passwordResetHandler))).Methods("POST")
+	r.Handle("/password-reset/confirm", authLimit(http.HandlerFunc(passwordResetConfirmHandler))).Methods("POST")
 
 	// protected routes
 	api := r.PathPrefix("/api").Subrouter()
-	api.Use(auth.AuthMiddleware)
+	api.Use(auth.AuthMiddleware, ratelimit.Middleware("api", ratelimit.IP, userKey))
 	api.HandleFunc("/orders", createOrderHandler).Methods("POST")
 	api.HandleFunc("/orders", listOrdersHandler).Methods("GET")
+	api.HandleFunc("/orders/batch", batchCreateOrdersHandler).Methods("POST")
 	api.HandleFunc("/orders/{id}/cancel", cancelOrderHandler).Methods("POST")
+	api.HandleFunc("/me", getProfileHandler).Methods("GET")
+	api.HandleFunc("/me", putProfileHandler).Methods("PUT")
+	api.HandleFunc("/me", deleteAccountHandler).Methods("DELETE")
+	api.HandleFunc("/me/export", exportAccountHandler).Methods("GET")
+	api.HandleFunc("/me/password", changePasswordHandler).Methods("POST")
+	api.HandleFunc("/me/2fa", getTwoFactorHandler).Methods("GET")
+	api.HandleFunc("/me/2fa/enroll", enrollTwoFactorHandler).Methods("POST")
+	api.HandleFunc("/me/2fa/confirm", confirmTwoFactorHandler).Methods("POST")
+	api.HandleFunc("/me/2fa/recovery-codes", regenerateRecoveryCodesHandler).Methods("POST")
+	api.HandleFunc("/me/2fa/disable", disableTwoFactorHandler).Methods("POST")
+	api.HandleFunc("/me/notifications", getNotificationPrefsHandler).Methods("GET")
+	api.HandleFunc("/me/notifications", putNotificationPrefsHandler).Methods("PUT")
+	api.HandleFunc("/courier/route", courierRouteHandler).Methods("GET")
+	api.HandleFunc("/courier/position", courierPositionHandler).Methods("PUT")
 
 	// admin
-	api.HandleFunc("/admin/orders", adminListOrdersHandler).Methods("GET")
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/orders", adminListOrdersHandler).Methods("GET")
+	admin.HandleFunc("/orders/export", exportOrdersHandler).Methods("GET")
+	admin.HandleFunc("/orders/{id}/status", adminSetOrderStatusHandler).Methods("POST")
+	admin.HandleFunc("/reports/status-daily", statusDailyReport).Methods("GET")
+	admin.HandleFunc("/reports/delivery-time", deliveryTimeReport).Methods("GET")
+	admin.HandleFunc("/reports/cancellation-rate", cancellationReport).Methods("GET")
+	admin.HandleFunc("/users", adminListUsersHandler).Methods("GET")
+	admin.HandleFunc("/users", adminCreateUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{id}", adminGetUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}", adminDeleteUserHandler).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/export", adminExportUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}/role", adminSetUserRoleHandler).Methods("PUT")
+	admin.HandleFunc("/users/{id}/disable", adminSetUserDisabledHandler(true)).Methods("POST")
+	admin.HandleFunc("/users/{id}/enable", adminSetUserDisabledHandler(false)).Methods("POST")
+	admin.HandleFunc("/users/{id}/logout", adminLogoutUserHandler).Methods("POST")
+	admin.HandleFunc("/orders/{id}/courier", adminAssignCourierHandler).Methods("PUT")
+	admin.HandleFunc("/couriers", adminListCouriersHandler).Methods("GET")
+	admin.HandleFunc("/couriers/{id}", adminGetCourierHandler).Methods("GET")
+	admin.HandleFunc("/zones", adminListZonesHandler).Methods("GET")
+	admin.HandleFunc("/zones", adminCreateZoneHandler).Methods("POST")
+	admin.HandleFunc("/zones/{id}", adminGetZoneHandler).Methods("GET")
+	admin.HandleFunc("/zones/{id}", adminUpdateZoneHandler).Methods("PUT")
+	admin.HandleFunc("/merchants", createMerchantHandler).Methods("POST")
+	admin.HandleFunc("/api-keys", createAPIKeyHandler).Methods("POST")
+	admin.HandleFunc("/api-keys", listAPIKeysHandler).Methods("GET")
+	admin.HandleFunc("/api-keys/{id}", revokeAPIKeyHandler).Methods("DELETE")
 }
 
 // Simple JSON helpers
@@ -40,53 +105,121 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type registerReq struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role"` // customer or admin
+	Username string `json:"username" validate:"required,min=3,max=32,username"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72,password"`
+	// Role is customer or courier; admins are created by other admins with
+	// POST /api/admin/users
+	Role string `json:"role" validate:"required,oneof=customer courier"`
+	// Merchant is the slug of the tenant to join; defaults to the operator merchant
+	Merchant string `json:"merchant" validate:"max=64,slug"`
+	// DisplayName, Email and Phone are optional; order notifications go to Email and Phone
+	DisplayName string `json:"display_name" validate:"max=100"`
+	Email       string `json:"email" validate:"max=254,email"`
+	Phone       string `json:"phone" validate:"phone"`
 }
 
 func registerHandler(w http.ResponseWriter, r *http.Request) {
 	var req registerReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
 		return
 	}
-	if req.Username == "" || req.Password == "" || req.Role == "" {
-		http.Error(w, "missing fields", http.StatusBadRequest)
+	if req.Merchant == "" {
+		req.Merchant = "default"
+	}
+	m, err := models.GetMerchantBySlug(r.Context(), req.Merchant)
+	if err != nil {
+		writeRequestError(w, validate.Errors{{Field: "merchant", Message: "is not a known merchant"}})
+		return
+	}
+	u, err := models.CreateUser(database.WithTenant(r.Context(), m.ID), req.Username, req.Password, req.Role,
+		models.Profile{DisplayName: strings.TrimSpace(req.DisplayName), Email: req.Email, Phone: req.Phone})
+	if isUniqueViolation(err) {
+		writeJSON(w, errorResponse{Error: "username already exists", Fields: validate.Errors{{Field: "username", Message: "is already taken"}}}, http.StatusConflict)
 		return
 	}
-	u, err := models.CreateUser(r.Context(), req.Username, req.Password, req.Role)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		internalError(w, r, err)
 		return
 	}
-	writeJSON(w, map[string]interface{}{"id": u.ID, "username": u.Username, "role": u.Role}, http.StatusCreated)
+	writeJSON(w, map[string]interface{}{"id": u.ID, "username": u.Username, "role": u.Role, "tenant_id": u.TenantID}, http.StatusCreated)
 }
 
 type loginReq struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,max=32"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
 	var req loginReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	logger := logging.FromContext(r.Context())
+	ip := ratelimit.ClientIP(r)
+	if wait, err := ratelimit.LockedOut(r.Context(), req.Username, ip); err != nil {
+		logger.Error("lockout check failed", "error", err)
+	} else if wait > 0 {
+		ratelimit.RetryLater(w, wait)
 		return
 	}
-	u, err := models.GetUserByUsername(r.Context(), req.Username)
+	// usernames are global; the user row tells us which tenant the token is for
+	u, err := models.GetUserByUsername(database.WithSystem(r.Context()), req.Username)
 	if err != nil || !u.CheckPassword(req.Password) {
+		if wait, err := ratelimit.RecordLoginFailure(r.Context(), req.Username, ip); err != nil {
+			logger.Error("failed to record login failure", "error", err)
+		} else if wait > 0 {
+			logger.Warn("username locked out after repeated failed logins",
+				"username", req.Username, "client_ip", ip, "lockout", wait)
+		}
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
-	token, err := auth.GenerateToken(u.ID, u.Role)
+	if err := ratelimit.ResetLoginFailures(r.Context(), req.Username, ip); err != nil {
+		logger.Error("failed to reset login failures", "error", err)
+	}
+	if u.DisabledAt != nil {
+		http.Error(w, "account disabled", http.StatusForbidden)
+		return
+	}
+	if u.TOTPEnabled {
+		// the password was right; the token comes from /login/2fa with a code
+		challenge, err := auth.StartLoginChallenge(r.Context(), u)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"mfa_required": true, "challenge": challenge}, http.StatusOK)
+		return
+	}
+	if err := models.TouchLogin(database.WithTenant(r.Context(), u.TenantID), u.ID); err != nil {
+		logger.Error("failed to record login", "user_id", u.ID, "error", err)
+	}
+	token, err := auth.GenerateToken(u.ID, u.TenantID, u.Role, false)
 	if err != nil {
+		logger.Error("token generation failed", "user_id", u.ID, "error", err)
 		http.Error(w, "could not generate token", http.StatusInternalServerError)
 		return
 	}
 	writeJSON(w, map[string]string{"token": token}, http.StatusOK)
 }
 
+// rate limit keys for the auth and api route groups
+var (
+	usernameKey = ratelimit.Key{Name: "username", Fn: bodyUsername}
+	userKey     = ratelimit.Key{Name: "user", Fn: func(r *http.Request) string {
+		claims, err := getClaims(r)
+		if err != nil {
+			return ""
+		}
+		if claims.KeyID != 0 {
+			return "key-" + strconv.Itoa(claims.KeyID)
+		}
+		return strconv.Itoa(claims.UserID)
+	}}
+)
+
 func getClaims(r *http.Request) (*auth.Claims, error) {
 	c := r.Context().Value("claims")
 	if c == nil {
@@ -99,8 +232,42 @@ func getClaims(r *http.Request) (*auth.Claims, error) {
 	return claims, nil
 }
 
+// requireScope replies 403 and returns false unless the caller may use scope.
+func requireScope(w http.ResponseWriter, claims *auth.Claims, scope string) bool {
+	if !claims.Allows(scope) {
+		http.Error(w, "api key lacks scope "+scope, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 type createOrderReq struct {
-	Item string `json:"item"`
+	Item string `json:"item" validate:"required,max=200"`
+	// CustomerID is required from merchant API keys, which order on a customer's behalf
+	CustomerID int `json:"customer_id" validate:"min=1"`
+	// Pickup and Dropoff are required once the merchant has service zones
+	Pickup  *geo.Point `json:"pickup"`
+	Dropoff *geo.Point `json:"dropoff"`
+	// DeliverAfter and DeliverBefore are an optional delivery window; either end
+	// may be left open
+	DeliverAfter  *time.Time `json:"deliver_after"`
+	DeliverBefore *time.Time `json:"deliver_before"`
+}
+
+// check validates what the struct tags can't: the positions and the window
+func (req *createOrderReq) check() validate.Errors {
+	errs := checkPositions(req.Pickup, req.Dropoff)
+	if req.DeliverAfter != nil && req.DeliverBefore != nil && !req.DeliverBefore.After(*req.DeliverAfter) {
+		errs = append(errs, validate.FieldError{Field: "deliver_before", Message: "must be after deliver_after"})
+	}
+	return errs
+}
+
+func (req *createOrderReq) newOrder(customerID int, zoneID *int) models.NewOrder {
+	return models.NewOrder{
+		CustomerID: customerID, Item: req.Item, Pickup: req.Pickup, Dropoff: req.Dropoff, ZoneID: zoneID,
+		DeliverAfter: req.DeliverAfter, DeliverBefore: req.DeliverBefore,
+	}
 }
 
 func createOrderHandler(w http.ResponseWriter, r *http.Request) {
@@ -109,32 +276,83 @@ func createOrderHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauth", http.StatusUnauthorized)
 		return
 	}
+	if !requireScope(w, claims, auth.ScopeOrdersWrite) {
+		return
+	}
 	var req createOrderReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if errs := req.check(); errs != nil {
+		writeRequestError(w, errs)
+		return
+	}
+	customerID, ferr := orderCustomer(claims, req.CustomerID)
+	if ferr != nil {
+		writeRequestError(w, validate.Errors{*ferr})
+		return
+	}
+	if claims.Role == auth.RoleMerchant {
+		// the lookup is tenant scoped, so another merchant's customers are not found
+		if u, err := models.GetUserByID(r.Context(), customerID); err != nil || u.DeletedAt != nil {
+			writeRequestError(w, validate.Errors{unknownCustomer})
+			return
+		}
+	}
+	zones, err := models.ListZones(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	zoneID, errs := locateOrder(zones, req.Pickup, req.Dropoff)
+	if errs != nil {
+		writeRequestError(w, errs)
 		return
 	}
-	ord, err := models.CreateOrder(r.Context(), claims.UserID, req.Item)
+	ord, err := models.CreateOrder(r.Context(), req.newOrder(customerID, zoneID))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, r, err)
 		return
 	}
+	metrics.ObserveTransition("none", ord.Status)
+	eta.Refresh(r.Context(), ord)
 	// start progression in background
 	orders.StartProgression(r.Context(), ord.ID)
 	writeJSON(w, ord, http.StatusCreated)
 }
 
+var unknownCustomer = validate.FieldError{Field: "customer_id", Message: "is not a known customer"}
+
+// orderCustomer returns who an order is for: the caller, or for merchant API keys
+// the customer_id they supplied. Merchant customers still need an existence check.
+func orderCustomer(claims *auth.Claims, customerID int) (int, *validate.FieldError) {
+	if claims.Role == auth.RoleMerchant {
+		if customerID == 0 {
+			return 0, &validate.FieldError{Field: "customer_id", Message: "is required"}
+		}
+		return customerID, nil
+	}
+	if customerID != 0 {
+		return 0, &validate.FieldError{Field: "customer_id", Message: "is only accepted with a merchant API key"}
+	}
+	return claims.UserID, nil
+}
+
 func listOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	claims, err := getClaims(r)
 	if err != nil {
 		http.Error(w, "unauth", http.StatusUnauthorized)
 		return
 	}
-	if claims.Role == "admin" {
-		// admin can view all
+	if !requireScope(w, claims, auth.ScopeOrdersRead) {
+		return
+	}
+	if claims.Role == "admin" || claims.Role == auth.RoleMerchant {
+		// admins and merchant keys see every order in their tenant
 		all, err := models.ListAllOrders(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 		writeJSON(w, all, http.StatusOK)
@@ -143,7 +361,7 @@ func listOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	// customer: only their orders
 	list, err := models.ListOrdersByCustomer(r.Context(), claims.UserID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, r, err)
 		return
 	}
 	writeJSON(w, list, http.StatusOK)
@@ -155,42 +373,247 @@ func cancelOrderHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauth", http.StatusUnauthorized)
 		return
 	}
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	id, _ := strconv.Atoi(idStr)
-	// customers may cancel only their own orders
+	if !requireScope(w, claims, auth.ScopeOrdersWrite) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	// customers may cancel only their own orders; admins and merchant keys any in their tenant
 	ord, err := models.GetOrderByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, "order not found", http.StatusNotFound)
 		return
 	}
-	if claims.Role != "admin" && ord.CustomerID != claims.UserID {
+	if claims.Role != "admin" && claims.Role != auth.RoleMerchant && ord.CustomerID != claims.UserID {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
-	// Cancel in DB
-	if err := models.CancelOrder(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Cancel in DB; the order may have moved on since we read it
+	final, from, err := models.CancelOrder(r.Context(), id)
+	if err != nil {
+		internalError(w, r, err)
 		return
 	}
-	// stop progression if running
-	orders.CancelProgression(id)
-	// publish cancellation
-	orders.PublishImmediateUpdate(r.Context(), id, "cancelled")
-	writeJSON(w, map[string]string{"status": "cancelled"}, http.StatusOK)
+	if from != "" {
+		metrics.ObserveTransition(from, "cancelled")
+		// stop progression if running
+		orders.CancelProgression(r.Context(), id)
+		// publish cancellation
+		orders.PublishImmediateUpdate(r.Context(), id, "cancelled")
+	}
+	// the order's real state: cancelled, or delivered if it got there first
+	writeJSON(w, map[string]string{"status": final.Status}, http.StatusOK)
 }
 
 func adminListOrdersHandler(w http.ResponseWriter, r *http.Request) {
-	// show all orders (admin-only)
+	// show all orders (admin-only), optionally filtered
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	f, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	all, err := models.ListOrders(r.Context(), f)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, all, http.StatusOK)
+}
+
+type setOrderStatusReq struct {
+	Status string `json:"status" validate:"required,oneof=created dispatched in_transit delivered cancelled"`
+	Reason string `json:"reason" validate:"required,max=500"`
+	// Override allows moves outside the normal lifecycle, e.g. from delivered back to dispatched
+	Override bool `json:"override"`
+}
+
+// adminSetOrderStatusHandler lets an admin force an order's status, e.g. after a courier
+// app glitch. The move must pass orders.CheckTransition and is recorded with its reason
+// and the admin who made it.
+func adminSetOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireAdmin(w, r)
+	if claims == nil {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	var req setOrderStatusReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		writeRequestError(w, validate.Errors{{Field: "reason", Message: "is required"}})
+		return
+	}
+	// uncached: ChangeOrderStatus compares against this status
+	ord, err := models.GetOrderUncached(r.Context(), id)
+	if err != nil {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return
+	}
+	if err := orders.CheckTransition(ord.Status, req.Status, req.Override); err != nil {
+		writeRequestError(w, validate.Errors{{Field: "status", Message: err.Error()}})
+		return
+	}
+
+	// stop our progression so it can't advance the order under us; one running on
+	// another replica loses its next compare-and-set and stops by itself
+	orders.CancelProgression(r.Context(), id)
+	change := &models.StatusChange{
+		OrderID:   id,
+		From:      ord.Status,
+		To:        req.Status,
+		Reason:    req.Reason,
+		Override:  req.Override,
+		ChangedBy: claims.UserID,
+	}
+	updated, err := models.ChangeOrderStatus(r.Context(), change)
+	if err != nil || updated == nil {
+		// nothing changed: put progression back for whatever state the order is in
+		orders.StartProgression(r.Context(), id)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		http.Error(w, "order status changed concurrently; reload and retry", http.StatusConflict)
+		return
+	}
+	metrics.ObserveTransition(change.From, change.To)
+	orders.PublishImmediateUpdate(r.Context(), id, change.To)
+	if orders.Active(change.To) {
+		orders.StartProgression(r.Context(), id)
+	}
+	logging.FromContext(r.Context()).Info("order status set by admin",
+		"order_id", id, "from", change.From, "to", change.To, "override", change.Override,
+		"reason", change.Reason, "user_id", claims.UserID)
+	writeJSON(w, map[string]interface{}{"order": updated, "change": change}, http.StatusOK)
+}
+
+type createMerchantReq struct {
+	Slug string `json:"slug" validate:"required,min=2,max=64,slug"`
+	Name string `json:"name" validate:"required,max=200"`
+}
+
+func createMerchantHandler(w http.ResponseWriter, r *http.Request) {
+	// only the platform operator's admins onboard merchants
+	claims, err := getClaims(r)
+	if err != nil || claims.Role != "admin" || claims.TenantID != models.OperatorTenantID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	var req createMerchantReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	m, err := models.CreateMerchant(r.Context(), req.Slug, req.Name)
+	if isUniqueViolation(err) {
+		http.Error(w, "merchant slug already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, m, http.StatusCreated)
+}
+
+type createAPIKeyReq struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,max=10"`
+	// ExpiresAt is optional; keys without it don't expire
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (req *createAPIKeyReq) check() error {
+	var errs validate.Errors
+	for _, s := range req.Scopes {
+		if !auth.ValidScope(s) {
+			errs = append(errs, validate.FieldError{Field: "scopes", Message: fmt.Sprintf("unknown scope %q; must be one of: %s", s, strings.Join(auth.Scopes, ", "))})
+			break
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, validate.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
 	claims, err := getClaims(r)
 	if err != nil || claims.Role != "admin" {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
-	all, err := models.ListAllOrders(r.Context())
+	var req createAPIKeyReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := req.check(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	key, k, err := models.CreateAPIKey(r.Context(), req.Name, req.Scopes, req.ExpiresAt, claims.UserID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		internalError(w, r, err)
 		return
 	}
-	writeJSON(w, all, http.StatusOK)
+	// the plaintext key is only ever shown here
+	writeJSON(w, map[string]interface{}{"key": key, "api_key": k}, http.StatusCreated)
+}
+
+func listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getClaims(r)
+	if err != nil || claims.Role != "admin" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	keys, err := models.ListAPIKeys(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, keys, http.StatusOK)
+}
+
+func revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := getClaims(r)
+	if err != nil || claims.Role != "admin" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	k, err := models.RevokeAPIKey(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, k, http.StatusOK)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
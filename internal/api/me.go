@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// requireUser replies 403 and returns nil unless the caller is a logged-in user;
+// API keys act for a merchant and have no profile of their own.
+func requireUser(w http.ResponseWriter, r *http.Request) *auth.Claims {
+	claims, err := getClaims(r)
+	if err != nil || claims.KeyID != 0 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil
+	}
+	return claims
+}
+
+// profileResp is the caller's account as they see it; the password hash stays out
+type profileResp struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	TenantID    int    `json:"tenant_id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+}
+
+func newProfileResp(u *models.User) profileResp {
+	return profileResp{ID: u.ID, Username: u.Username, Role: u.Role, TenantID: u.TenantID,
+		DisplayName: u.DisplayName, Email: u.Email, Phone: u.Phone}
+}
+
+func getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	u, err := models.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, newProfileResp(u), http.StatusOK)
+}
+
+type profileReq struct {
+	DisplayName string `json:"display_name" validate:"max=100"`
+	Email       string `json:"email" validate:"max=254,email"`
+	Phone       string `json:"phone" validate:"phone"`
+}
+
+// putProfileHandler replaces the caller's display name and contact details;
+// omitted fields are cleared.
+func putProfileHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req profileReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	u, err := models.UpdateProfile(r.Context(), claims.UserID, models.Profile{
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Email:       req.Email,
+		Phone:       req.Phone,
+	})
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, newProfileResp(u), http.StatusOK)
+}
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"current_password" validate:"required,maxbytes=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,maxbytes=72,password"`
+}
+
+// changePasswordHandler sets a new password after checking the current one, logs
+// the user out everywhere and returns a fresh token for the caller.
+func changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req changePasswordReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	u, err := models.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if !u.CheckPassword(req.CurrentPassword) {
+		writeRequestError(w, validate.Errors{{Field: "current_password", Message: "is incorrect"}})
+		return
+	}
+	if err := models.SetPassword(r.Context(), u.ID, req.NewPassword); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	token, err := auth.GenerateToken(u.ID, u.TenantID, u.Role, claims.MFA)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]string{"token": token}, http.StatusOK)
+}
+
+type passwordResetReq struct {
+	Username string `json:"username" validate:"required,max=32"`
+}
+
+// passwordResetHandler queues a reset token for the user's email or phone. It
+// answers 202 right away whether or not the user exists, so it can't be used to
+// probe for usernames.
+func passwordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	auth.RequestPasswordReset(r.Context(), req.Username)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type passwordResetConfirmReq struct {
+	Token       string `json:"token" validate:"required,max=100"`
+	NewPassword string `json:"new_password" validate:"required,min=8,maxbytes=72,password"`
+}
+
+func passwordResetConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetConfirmReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	err := auth.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if errors.Is(err, models.ErrInvalidResetToken) {
+		writeRequestError(w, validate.Errors{{Field: "token", Message: err.Error()}})
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getNotificationPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	p, err := models.GetNotificationPrefs(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, p, http.StatusOK)
+}
+
+type notificationPrefsReq struct {
+	EmailEnabled bool   `json:"email_enabled"`
+	SMSEnabled   bool   `json:"sms_enabled"`
+	Locale       string `json:"locale" validate:"required,max=10"`
+	QuietStart   string `json:"quiet_start" validate:"max=5"`
+	QuietEnd     string `json:"quiet_end" validate:"max=5"`
+	Timezone     string `json:"timezone" validate:"required,max=64"`
+}
+
+func (req *notificationPrefsReq) check() error {
+	var errs validate.Errors
+	if !notify.SupportedLocale(req.Locale) {
+		errs = append(errs, validate.FieldError{Field: "locale", Message: "must be one of: " + strings.Join(notify.Locales(), ", ")})
+	}
+	if (req.QuietStart == "") != (req.QuietEnd == "") {
+		errs = append(errs, validate.FieldError{Field: "quiet_end", Message: "quiet_start and quiet_end must be set together"})
+	}
+	for field, v := range map[string]string{"quiet_start": req.QuietStart, "quiet_end": req.QuietEnd} {
+		if _, err := notify.ParseClock(v); v != "" && err != nil {
+			errs = append(errs, validate.FieldError{Field: field, Message: "must be a HH:MM time of day"})
+		}
+	}
+	if req.QuietStart != "" && req.QuietStart == req.QuietEnd {
+		errs = append(errs, validate.FieldError{Field: "quiet_end", Message: "must differ from quiet_start"})
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		errs = append(errs, validate.FieldError{Field: "timezone", Message: fmt.Sprintf("unknown time zone %q", req.Timezone)})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// putNotificationPrefsHandler replaces the caller's notification preferences.
+func putNotificationPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req notificationPrefsReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := req.check(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	p := &models.NotificationPrefs{
+		UserID:       claims.UserID,
+		EmailEnabled: req.EmailEnabled,
+		SMSEnabled:   req.SMSEnabled,
+		Locale:       req.Locale,
+		QuietStart:   req.QuietStart,
+		QuietEnd:     req.QuietEnd,
+		Timezone:     req.Timezone,
+	}
+	if err := models.SaveNotificationPrefs(r.Context(), p); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, p, http.StatusOK)
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPISpec is the OpenAPI 3 document describing every route in RegisterRoutes.
+// Keep it in sync when adding or changing routes; the contract test in
+// internal/tests fails when the router and the spec drift apart.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+func openapiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(OpenAPISpec)
+}
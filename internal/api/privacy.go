@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/privacy"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+type deleteAccountReq struct {
+	Password string `json:"password" validate:"required,maxbytes=72"`
+	// Code is required when two-factor is enabled
+	Code string `json:"code" validate:"max=32"`
+}
+
+// deleteAccountHandler deletes the caller's account after checking their password
+// and, if they use two-factor, a code. Their orders are kept, anonymized; the
+// token used for this request stops working.
+func deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req deleteAccountReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	u, err := models.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if !u.CheckPassword(req.Password) {
+		writeRequestError(w, validate.Errors{{Field: "password", Message: "is incorrect"}})
+		return
+	}
+	if u.TOTPEnabled {
+		if req.Code == "" {
+			writeRequestError(w, validate.Errors{{Field: "code", Message: "is required"}})
+			return
+		}
+		if err := auth.VerifySecondFactor(r.Context(), u.ID, req.Code); err != nil {
+			writeTwoFactorError(w, r, err)
+			return
+		}
+	}
+	if !accountDeleted(w, r, privacy.DeleteAccount(r.Context(), u.ID)) {
+		return
+	}
+	logging.FromContext(r.Context()).Info("account deleted by its user", "user_id", u.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// accountDeleted reports whether privacy.DeleteAccount succeeded, replying 409
+// for a user with orders in progress, or 404 or 500, if not.
+func accountDeleted(w http.ResponseWriter, r *http.Request, err error) bool {
+	if errors.Is(err, models.ErrActiveOrders) {
+		http.Error(w, "account has orders in progress; cancel them or wait until they are delivered", http.StatusConflict)
+		return false
+	}
+	return userFound(w, r, err)
+}
+
+// exportAccountHandler returns everything held about the caller as JSON.
+func exportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	e, err := privacy.ExportAccount(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, e, http.StatusOK)
+}
+
+// adminDeleteUserHandler deletes another user's account, e.g. to honour an
+// erasure request that came in through support.
+func adminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims, id, ok := userTarget(w, r, false)
+	if !ok {
+		return
+	}
+	if !accountDeleted(w, r, privacy.DeleteAccount(r.Context(), id)) {
+		return
+	}
+	logging.FromContext(r.Context()).Info("account deleted by admin", "user_id", id, "by", claims.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminExportUserHandler returns everything held about a user, e.g. to answer an
+// access request that came in through support.
+func adminExportUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims, id, ok := userTarget(w, r, true)
+	if !ok {
+		return
+	}
+	e, err := privacy.ExportAccount(r.Context(), id)
+	if !userFound(w, r, err) {
+		return
+	}
+	logging.FromContext(r.Context()).Info("account exported by admin", "user_id", id, "by", claims.UserID)
+	writeJSON(w, e, http.StatusOK)
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/reports"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// exportFlushRows is how many rows an export writes between flushes
+const exportFlushRows = 500
+
+// exportWriteWindow is how long each flushed chunk of an export may take to write;
+// the server's write timeout would otherwise cut long exports short
+const exportWriteWindow = 30 * time.Second
+
+var orderStatuses = []string{"created", "dispatched", "in_transit", "delivered", "cancelled"}
+
+// parseOrderFilter reads the listing filters shared by admin listing, export and
+// reports: status, customer_id, zone_id, from and to. from/to accept RFC 3339
+// timestamps or YYYY-MM-DD dates; a date for to includes that whole day.
+func parseOrderFilter(q url.Values) (models.OrderFilter, error) {
+	var f models.OrderFilter
+	var errs validate.Errors
+	if s := q.Get("status"); s != "" {
+		if !contains(orderStatuses, s) {
+			errs = append(errs, validate.FieldError{Field: "status", Message: "must be one of: created, dispatched, in_transit, delivered, cancelled"})
+		}
+		f.Status = s
+	}
+	if s := q.Get("customer_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			errs = append(errs, validate.FieldError{Field: "customer_id", Message: "must be a positive integer"})
+		}
+		f.CustomerID = id
+	}
+	if s := q.Get("zone_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			errs = append(errs, validate.FieldError{Field: "zone_id", Message: "must be a positive integer"})
+		}
+		f.ZoneID = id
+	}
+	parseTime := func(field string, endOfDay bool) time.Time {
+		s := q.Get(field)
+		if s == "" {
+			return time.Time{}
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			if endOfDay {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t
+		}
+		errs = append(errs, validate.FieldError{Field: field, Message: "must be an RFC 3339 timestamp or a YYYY-MM-DD date"})
+		return time.Time{}
+	}
+	f.From = parseTime("from", false)
+	f.To = parseTime("to", true)
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		errs = append(errs, validate.FieldError{Field: "to", Message: "must be after from"})
+	}
+	if len(errs) > 0 {
+		return f, errs
+	}
+	return f, nil
+}
+
+// optionalID formats a nullable id for CSV, empty for NULL
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin replies 403 and returns nil unless the caller is an admin.
+func requireAdmin(w http.ResponseWriter, r *http.Request) *auth.Claims {
+	claims, err := getClaims(r)
+	if err != nil || claims.Role != "admin" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil
+	}
+	return claims
+}
+
+var exportHeader = []string{"id", "tenant_id", "customer_id", "item", "status", "created_at", "updated_at", "zone_id"}
+
+// exportOrdersHandler streams the orders matching the listing filters as CSV
+// (default) or NDJSON, newest first.
+func exportOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	q := r.URL.Query()
+	f, err := parseOrderFilter(q)
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		ferr := validate.FieldError{Field: "format", Message: "must be csv or ndjson"}
+		if verrs, ok := err.(validate.Errors); ok {
+			err = append(verrs, ferr)
+		} else {
+			err = validate.Errors{ferr}
+		}
+	}
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	filename := "orders-" + time.Now().UTC().Format("20060102") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+
+	var write func(*models.Order) error
+	var flush func() error
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportHeader); err != nil {
+			return
+		}
+		write = func(o *models.Order) error {
+			return cw.Write([]string{
+				strconv.Itoa(o.ID), strconv.Itoa(o.TenantID), strconv.Itoa(o.CustomerID), o.Item, o.Status,
+				o.CreatedAt.UTC().Format(time.RFC3339), o.UpdatedAt.UTC().Format(time.RFC3339), optionalID(o.ZoneID),
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(o *models.Order) error { return enc.Encode(o) }
+		flush = func() error { return nil }
+	}
+
+	n := 0
+	err = models.EachOrder(r.Context(), f, func(o *models.Order) error {
+		if err := write(o); err != nil {
+			return err
+		}
+		n++
+		if n%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			_ = rc.Flush()
+			_ = rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// headers and maybe rows are already sent, so the client sees a truncated file
+		logging.FromContext(r.Context()).Error("order export failed", "rows", n, "error", err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("orders exported", "format", format, "rows", n)
+}
+
+// reportHandler serves a report computed by fn over the listing filters, cached per
+// tenant and filter set.
+func reportHandler(name string, fn func(ctx context.Context, f models.OrderFilter) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := requireAdmin(w, r)
+		if claims == nil {
+			return
+		}
+		f, err := parseOrderFilter(r.URL.Query())
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		key := fmt.Sprintf("%d:%s:%d:%d:%d:%d", claims.TenantID, f.Status, f.CustomerID, f.ZoneID, f.From.Unix(), f.To.Unix())
+		body, hit, err := reports.Get(r.Context(), name, key, func(ctx context.Context) (interface{}, error) {
+			return fn(ctx, f)
+		})
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if hit {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
+		}
+		_, _ = w.Write(body)
+	}
+}
+
+var (
+	statusDailyReport = reportHandler("status-daily", func(ctx context.Context, f models.OrderFilter) (interface{}, error) {
+		return models.OrdersPerStatusPerDay(ctx, f)
+	})
+	deliveryTimeReport = reportHandler("delivery-time", func(ctx context.Context, f models.OrderFilter) (interface{}, error) {
+		return models.AverageDeliveryTime(ctx, f)
+	})
+	cancellationReport = reportHandler("cancellation-rate", func(ctx context.Context, f models.OrderFilter) (interface{}, error) {
+		return models.CancellationRates(ctx, f)
+	})
+)
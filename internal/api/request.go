@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// maxBodyBytes caps the size of any JSON request body
+const maxBodyBytes = 1 << 20
+
+// errorResponse is the uniform shape of request errors returned to clients
+type errorResponse struct {
+	Error  string                `json:"error"`
+	Fields []validate.FieldError `json:"fields,omitempty"`
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// decodeJSON reads a single JSON object from the request body into dst,
+// rejecting unknown fields and oversized bodies, then runs validate.Struct on it.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	// only one JSON value per body
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return validate.Errors{{Field: "body", Message: "must contain a single JSON object"}}
+	}
+	return validate.Struct(dst)
+}
+
+// decodeError maps encoding/json failures to field-level errors where possible.
+func decodeError(err error) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return errBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return validate.Errors{{Field: "body", Message: "is required"}}
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &syntaxErr):
+		return validate.Errors{{Field: "body", Message: "is not valid JSON"}}
+	case errors.As(err, &typeErr):
+		return validate.Errors{{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.String()}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return validate.Errors{{Field: field, Message: "is not a recognised field"}}
+	}
+	return validate.Errors{{Field: "body", Message: err.Error()}}
+}
+
+// bodyUsername peeks at the "username" field of a JSON body, leaving the body
+// intact for the handler. Used to key rate limits on /login and /register.
+func bodyUsername(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	head, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	// put back what we read, followed by anything we didn't, so size limits still apply
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+	var peek struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(head, &peek) != nil {
+		return ""
+	}
+	return strings.TrimSpace(peek.Username)
+}
+
+// pathID parses a positive integer route variable such as {id}.
+func pathID(r *http.Request, name string) (int, error) {
+	raw := mux.Vars(r)[name]
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, validate.Errors{{Field: name, Message: fmt.Sprintf("must be a positive integer, got %q", raw)}}
+	}
+	return id, nil
+}
+
+// writeRequestError writes err from decodeJSON/pathID in the uniform error format.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var verrs validate.Errors
+	switch {
+	case errors.As(err, &verrs):
+		writeJSON(w, errorResponse{Error: "validation failed", Fields: verrs}, http.StatusBadRequest)
+	case errors.Is(err, errBodyTooLarge):
+		writeJSON(w, errorResponse{Error: err.Error()}, http.StatusRequestEntityTooLarge)
+	default:
+		writeJSON(w, errorResponse{Error: err.Error()}, http.StatusBadRequest)
+	}
+}
+
+// internalError logs err with the request-scoped logger and replies 500. The
+// client gets a generic message; database and driver errors stay in the log,
+// found by the request id.
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("request failed", "error", err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+type codeReq struct {
+	// Code is a 6-digit TOTP code or a recovery code
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// writeTwoFactorError maps the auth package's two-factor errors to responses.
+func writeTwoFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCode):
+		writeRequestError(w, validate.Errors{{Field: "code", Message: err.Error()}})
+	case errors.Is(err, models.ErrTOTPEnabled), errors.Is(err, auth.ErrNoTwoFactor):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		internalError(w, r, err)
+	}
+}
+
+// issueToken replies with a new session token for the caller.
+func issueToken(w http.ResponseWriter, r *http.Request, claims *auth.Claims, mfa bool, extra map[string]interface{}) {
+	token, err := auth.GenerateToken(claims.UserID, claims.TenantID, claims.Role, mfa)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	resp := map[string]interface{}{"token": token}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	writeJSON(w, resp, http.StatusOK)
+}
+
+type loginTwoFactorReq struct {
+	Challenge string `json:"challenge" validate:"required,max=100"`
+	Code      string `json:"code" validate:"required,max=32"`
+}
+
+// loginTwoFactorHandler is the second login step: it exchanges the challenge from
+// /login and a code for a session token.
+func loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginTwoFactorReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	u, err := auth.CompleteLoginChallenge(r.Context(), req.Challenge, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrInvalidCode), errors.Is(err, auth.ErrChallengeExpired):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		internalError(w, r, err)
+		return
+	}
+	if u.DisabledAt != nil {
+		http.Error(w, "account disabled", http.StatusForbidden)
+		return
+	}
+	if err := models.TouchLogin(database.WithTenant(r.Context(), u.TenantID), u.ID); err != nil {
+		logging.FromContext(r.Context()).Error("failed to record login", "user_id", u.ID, "error", err)
+	}
+	token, err := auth.GenerateToken(u.ID, u.TenantID, u.Role, true)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]string{"token": token}, http.StatusOK)
+}
+
+func getTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	t, err := models.GetTOTP(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	left := 0
+	if t.Enabled {
+		if left, err = models.RecoveryCodesLeft(r.Context(), claims.UserID); err != nil {
+			internalError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, map[string]interface{}{"enabled": t.Enabled, "recovery_codes_left": left}, http.StatusOK)
+}
+
+// enrollTwoFactorHandler starts enrollment: it returns a new secret and the URI to
+// show as a QR code. Calling it again before confirming replaces the secret.
+func enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	u, err := models.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	secret, uri, err := auth.BeginEnrollment(r.Context(), u)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]string{"secret": secret, "provisioning_uri": uri}, http.StatusOK)
+}
+
+// confirmTwoFactorHandler enables two-factor with a code from the newly enrolled
+// app. It returns the recovery codes, and a token that counts as two-factor
+// authenticated so admins don't have to log in again.
+func confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req codeReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	codes, err := auth.ConfirmEnrollment(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+	issueToken(w, r, claims, true, map[string]interface{}{"recovery_codes": codes})
+}
+
+func regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req codeReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	codes, err := auth.RegenerateRecoveryCodes(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"recovery_codes": codes}, http.StatusOK)
+}
+
+// disableTwoFactorHandler switches two-factor off, logs the user out everywhere
+// and returns a fresh token. If the old tokens can't be revoked no token is
+// issued. Admins can only enroll again until they do.
+func disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireUser(w, r)
+	if claims == nil {
+		return
+	}
+	var req codeReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := auth.DisableTwoFactor(r.Context(), claims.UserID, req.Code); err != nil {
+		writeTwoFactorError(w, r, err)
+		return
+	}
+	// tokens minted under two-factor must not outlive it
+	if err := auth.RevokeSessions(r.Context(), claims.UserID); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	issueToken(w, r, claims, false, nil)
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+// page sizes for GET /api/admin/users
+const (
+	defaultUserPage = 50
+	maxUserPage     = 200
+)
+
+// userResp is a user as admins see it
+type userResp struct {
+	profileResp
+	TwoFactor  bool       `json:"two_factor"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	DeletedAt  *time.Time `json:"deleted_at"`
+}
+
+func newUserResp(u *models.User) userResp {
+	return userResp{profileResp: newProfileResp(u), TwoFactor: u.TOTPEnabled, CreatedAt: u.CreatedAt,
+		DisabledAt: u.DisabledAt, DeletedAt: u.DeletedAt}
+}
+
+func parseUserFilter(q url.Values) (models.UserFilter, error) {
+	f := models.UserFilter{Query: strings.TrimSpace(q.Get("q")), Limit: defaultUserPage}
+	var errs validate.Errors
+	if len(f.Query) > 100 {
+		errs = append(errs, validate.FieldError{Field: "q", Message: "must be at most 100 characters"})
+	}
+	if s := q.Get("role"); s != "" {
+		if !models.ValidRole(s) {
+			errs = append(errs, validate.FieldError{Field: "role", Message: "must be one of: customer, admin, courier"})
+		}
+		f.Role = s
+	}
+	if s := q.Get("disabled"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, validate.FieldError{Field: "disabled", Message: "must be true or false"})
+		}
+		f.Disabled = &b
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxUserPage {
+			errs = append(errs, validate.FieldError{Field: "limit", Message: "must be between 1 and " + strconv.Itoa(maxUserPage)})
+		}
+		f.Limit = n
+	}
+	if s := q.Get("after"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			errs = append(errs, validate.FieldError{Field: "after", Message: "must be a non-negative integer"})
+		}
+		f.After = n
+	}
+	if len(errs) > 0 {
+		return f, errs
+	}
+	return f, nil
+}
+
+// adminListUsersHandler lists the tenant's users by id, a page at a time. Pass a
+// page's next_after as after to get the next one.
+func adminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	f, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	users, err := models.ListUsers(r.Context(), f)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	resp := struct {
+		Users     []userResp `json:"users"`
+		NextAfter *int       `json:"next_after"`
+	}{Users: make([]userResp, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, newUserResp(u))
+	}
+	if len(users) == f.Limit {
+		resp.NextAfter = &users[len(users)-1].ID
+	}
+	writeJSON(w, resp, http.StatusOK)
+}
+
+type createUserReq struct {
+	Username string `json:"username" validate:"required,min=3,max=32,username"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72,password"`
+	Role     string `json:"role" validate:"required,oneof=customer admin courier"`
+	// Merchant is the slug of the tenant to create the user in, by default the
+	// caller's; only the operator's admins may name another
+	Merchant    string `json:"merchant" validate:"max=64,slug"`
+	DisplayName string `json:"display_name" validate:"max=100"`
+	Email       string `json:"email" validate:"max=254,email"`
+	Phone       string `json:"phone" validate:"phone"`
+}
+
+// adminCreateUserHandler creates a user with any role. It is how admins are
+// provisioned, since /register only signs up customers and couriers: the
+// operator's admins create a new merchant's first admin, who adds the rest.
+func adminCreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireAdmin(w, r)
+	if claims == nil {
+		return
+	}
+	var req createUserReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	tenantID := claims.TenantID
+	if req.Merchant != "" {
+		m, err := models.GetMerchantBySlug(r.Context(), req.Merchant)
+		if err != nil {
+			writeRequestError(w, validate.Errors{{Field: "merchant", Message: "is not a known merchant"}})
+			return
+		}
+		if m.ID != claims.TenantID && claims.TenantID != models.OperatorTenantID {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		tenantID = m.ID
+	}
+	u, err := models.CreateUser(database.WithTenant(r.Context(), tenantID), req.Username, req.Password, req.Role,
+		models.Profile{DisplayName: strings.TrimSpace(req.DisplayName), Email: req.Email, Phone: req.Phone})
+	if isUniqueViolation(err) {
+		http.Error(w, "username already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("user created by admin", "user_id", u.ID, "role", u.Role,
+		"tenant_id", u.TenantID, "by", claims.UserID)
+	writeJSON(w, newUserResp(u), http.StatusCreated)
+}
+
+// userTarget checks the caller is an admin and parses the {id} of the user they
+// act on, refusing their own account if self is false. It replies and returns
+// ok=false on failure.
+func userTarget(w http.ResponseWriter, r *http.Request, self bool) (claims *auth.Claims, id int, ok bool) {
+	if claims = requireAdmin(w, r); claims == nil {
+		return nil, 0, false
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return nil, 0, false
+	}
+	if !self && id == claims.UserID {
+		http.Error(w, "admins can't do this to their own account", http.StatusConflict)
+		return nil, 0, false
+	}
+	return claims, id, true
+}
+
+// userFound reports whether a user lookup or update succeeded, replying 404 or
+// 500 if not.
+func userFound(w http.ResponseWriter, r *http.Request, err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return false
+	}
+	return true
+}
+
+func adminGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	_, id, ok := userTarget(w, r, true)
+	if !ok {
+		return
+	}
+	u, err := models.GetUserByID(r.Context(), id)
+	if !userFound(w, r, err) {
+		return
+	}
+	writeJSON(w, newUserResp(u), http.StatusOK)
+}
+
+type setRoleReq struct {
+	Role string `json:"role" validate:"required,oneof=customer admin courier"`
+}
+
+// adminSetUserRoleHandler changes a user's role. Tokens carry the role, so the
+// user is logged out everywhere and picks up the new role at their next login.
+func adminSetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	claims, id, ok := userTarget(w, r, false)
+	if !ok {
+		return
+	}
+	var req setRoleReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	u, err := models.SetUserRole(r.Context(), id, req.Role)
+	if !userFound(w, r, err) {
+		return
+	}
+	if err := auth.RevokeSessions(r.Context(), id); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("user role changed", "user_id", id, "role", req.Role, "by", claims.UserID)
+	writeJSON(w, newUserResp(u), http.StatusOK)
+}
+
+// adminSetUserDisabledHandler returns the handler that disables or re-enables a
+// user. Disabling takes effect on the user's next request; their old tokens stay
+// invalid after re-enabling.
+func adminSetUserDisabledHandler(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, id, ok := userTarget(w, r, !disabled)
+		if !ok {
+			return
+		}
+		u, err := models.SetUserDisabled(r.Context(), id, disabled)
+		if !userFound(w, r, err) {
+			return
+		}
+		if disabled {
+			if err := auth.RevokeSessions(r.Context(), id); err != nil {
+				internalError(w, r, err)
+				return
+			}
+		}
+		logging.FromContext(r.Context()).Info("user disabled state changed", "user_id", id, "disabled", disabled, "by", claims.UserID)
+		writeJSON(w, newUserResp(u), http.StatusOK)
+	}
+}
+
+// adminLogoutUserHandler invalidates every token the user holds.
+func adminLogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims, id, ok := userTarget(w, r, true)
+	if !ok {
+		return
+	}
+	if _, err := models.GetUserByID(r.Context(), id); !userFound(w, r, err) {
+		return
+	}
+	if err := auth.RevokeSessions(r.Context(), id); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("user logged out by admin", "user_id", id, "by", claims.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
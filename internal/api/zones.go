@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+type zoneReq struct {
+	Name    string      `json:"name" validate:"required,max=100"`
+	Polygon geo.Polygon `json:"polygon"`
+	// Active defaults to true
+	Active *bool `json:"active"`
+}
+
+// decodeZone reads and validates a zone from the request body.
+func decodeZone(w http.ResponseWriter, r *http.Request) (*zoneReq, bool) {
+	var req zoneReq
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return nil, false
+	}
+	if err := req.Polygon.Validate(); err != nil {
+		writeRequestError(w, validate.Errors{{Field: "polygon", Message: err.Error()}})
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Active == nil {
+		active := true
+		req.Active = &active
+	}
+	return &req, true
+}
+
+// zoneSaved reports whether a zone write succeeded, replying 404, 409 for a
+// duplicate name, or 500 if not.
+func zoneSaved(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(w, "zone not found", http.StatusNotFound)
+	case isUniqueViolation(err):
+		http.Error(w, "a zone with this name already exists", http.StatusConflict)
+	default:
+		internalError(w, r, err)
+	}
+	return false
+}
+
+func adminListZonesHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	zones, err := models.ListZones(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if zones == nil {
+		zones = []*models.Zone{}
+	}
+	writeJSON(w, zones, http.StatusOK)
+}
+
+// adminCreateZoneHandler adds a service zone. Once a merchant has a zone, its
+// orders must be placed with positions inside an active one.
+func adminCreateZoneHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireAdmin(w, r)
+	if claims == nil {
+		return
+	}
+	req, ok := decodeZone(w, r)
+	if !ok {
+		return
+	}
+	z, err := models.CreateZone(r.Context(), req.Name, req.Polygon, *req.Active)
+	if !zoneSaved(w, r, err) {
+		return
+	}
+	logging.FromContext(r.Context()).Info("service zone created", "zone_id", z.ID, "by", claims.UserID)
+	writeJSON(w, z, http.StatusCreated)
+}
+
+func adminGetZoneHandler(w http.ResponseWriter, r *http.Request) {
+	if requireAdmin(w, r) == nil {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	z, err := models.GetZone(r.Context(), id)
+	if !zoneSaved(w, r, err) {
+		return
+	}
+	writeJSON(w, z, http.StatusOK)
+}
+
+// adminUpdateZoneHandler replaces a zone. Deactivating a zone stops it accepting
+// orders; orders already in it keep their zone.
+func adminUpdateZoneHandler(w http.ResponseWriter, r *http.Request) {
+	claims := requireAdmin(w, r)
+	if claims == nil {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	req, ok := decodeZone(w, r)
+	if !ok {
+		return
+	}
+	z, err := models.UpdateZone(r.Context(), id, req.Name, req.Polygon, *req.Active)
+	if !zoneSaved(w, r, err) {
+		return
+	}
+	logging.FromContext(r.Context()).Info("service zone updated", "zone_id", z.ID, "active", z.Active, "by", claims.UserID)
+	writeJSON(w, z, http.StatusOK)
+}
+
+// position is one of an order's positions with the request field it came from
+type position struct {
+	field string
+	pos   *geo.Point
+}
+
+func orderPositions(pickup, dropoff *geo.Point) []position {
+	return []position{{"pickup", pickup}, {"dropoff", dropoff}}
+}
+
+const invalidPosition = "must have lat between -90 and 90 and lng between -180 and 180"
+
+// checkPositions validates the positions an order was placed with, if any.
+func checkPositions(pickup, dropoff *geo.Point) validate.Errors {
+	var errs validate.Errors
+	for _, p := range orderPositions(pickup, dropoff) {
+		if p.pos != nil && !p.pos.Valid() {
+			errs = append(errs, validate.FieldError{Field: p.field, Message: invalidPosition})
+		}
+	}
+	return errs
+}
+
+// locateOrder checks an order's positions against the merchant's zones and returns
+// the zone it is tagged with: the zone of its drop-off. Merchants without any
+// zones aren't geofenced; their orders may omit positions and get no zone.
+func locateOrder(zones []*models.Zone, pickup, dropoff *geo.Point) (*int, validate.Errors) {
+	if len(zones) == 0 {
+		return nil, nil
+	}
+	var errs validate.Errors
+	var zone *models.Zone
+	for _, p := range orderPositions(pickup, dropoff) {
+		if p.pos == nil {
+			errs = append(errs, validate.FieldError{Field: p.field, Message: "is required"})
+			continue
+		}
+		z := models.ZoneAt(zones, *p.pos)
+		if z == nil {
+			errs = append(errs, validate.FieldError{Field: p.field, Message: "is outside every active service zone"})
+			continue
+		}
+		zone = z
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return &zone.ID, nil
+}
@@ -3,41 +3,62 @@ package auth
 import (
     "context"
     "errors"
-    "os"
-    "strconv"
     "time"
 
     "github.com/golang-jwt/jwt/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/config"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+    "github.com/rajnish-012/delivery-management-system/internal/logging"
     "net/http"
 )
 
-var jwtSecret = []byte(func() string {
-    s := os.Getenv("JWT_SECRET")
-    if s == "" {
-        s = "dev-secret" // change in production
-    }
-    return s
-}())
+var (
+    jwtSecret     []byte
+    tokenTTL      time.Duration
+    resetTokenTTL time.Duration
+    resetURL      string
+    totpIssuer    string
+)
+
+// Configure sets the signing secret, token lifetime and password reset settings; call it before issuing or parsing tokens
+func Configure(cfg config.AuthConfig) {
+    jwtSecret = []byte(cfg.JWTSecret)
+    tokenTTL = cfg.TokenTTL
+    resetTokenTTL = cfg.ResetTokenTTL
+    resetURL = cfg.ResetURL
+    totpIssuer = cfg.TOTPIssuer
+}
 
 type Claims struct {
-    UserID int    `json:"user_id"`
-    Role   string `json:"role"`
+    UserID   int    `json:"user_id"`
+    TenantID int    `json:"tenant_id"`
+    Role     string `json:"role"`
+    // MFA is set on tokens issued after a second factor was checked
+    MFA bool `json:"mfa,omitempty"`
+    // IssuedAtMicro is the issue time in unix microseconds; iat only has seconds,
+    // too coarse to tell a token from a revocation in the same second
+    IssuedAtMicro int64 `json:"iat_us,omitempty"`
+    // KeyID and Scopes are set for API key requests only; they are never put in a JWT
+    KeyID  int      `json:"-"`
+    Scopes []string `json:"-"`
     jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, role string) (string, error) {
-    expMinutes := 60
-    if v := os.Getenv("JWT_EXP_MINUTES"); v != "" {
-        if n, err := strconv.Atoi(v); err == nil {
-            expMinutes = n
-        }
+// GenerateToken issues a session token; mfa records that the user passed two-factor authentication
+func GenerateToken(userID, tenantID int, role string, mfa bool) (string, error) {
+    if len(jwtSecret) == 0 {
+        return "", errors.New("auth not configured")
     }
+    now := time.Now()
     claims := Claims{
-        UserID: userID,
-        Role:   role,
+        UserID:        userID,
+        TenantID:      tenantID,
+        Role:          role,
+        MFA:           mfa,
+        IssuedAtMicro: now.UnixMicro(),
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expMinutes) * time.Minute)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
+            ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+            IssuedAt:  jwt.NewNumericDate(now),
         },
     }
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -45,6 +66,9 @@ func GenerateToken(userID int, role string) (string, error) {
 }
 
 func ParseToken(tokenStr string) (*Claims, error) {
+    if len(jwtSecret) == 0 {
+        return nil, errors.New("auth not configured")
+    }
     token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
         return jwtSecret, nil
     })
@@ -57,9 +81,20 @@ func ParseToken(tokenStr string) (*Claims, error) {
     return nil, errors.New("invalid token")
 }
 
-// Middleware for routes (simple)
+// Middleware for routes (simple). Accepts a Bearer JWT or a merchant API key, puts the
+// same Claims in the context for both and scopes database access to the caller's tenant.
 func AuthMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if key := r.Header.Get(APIKeyHeader); key != "" {
+            claims, err := apiKeyClaims(r.Context(), key)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusUnauthorized)
+                return
+            }
+            ctx := context.WithValue(database.WithTenant(r.Context(), claims.TenantID), "claims", claims)
+            next.ServeHTTP(w, r.WithContext(ctx))
+            return
+        }
         auth := r.Header.Get("Authorization")
         if auth == "" {
             http.Error(w, "missing authorization header", http.StatusUnauthorized)
@@ -78,8 +113,29 @@ func AuthMiddleware(next http.Handler) http.Handler {
             http.Error(w, "invalid token", http.StatusUnauthorized)
             return
         }
+        // tokens issued before multi-tenancy have no tenant; make the client log in again
+        if claims.TenantID == 0 {
+            http.Error(w, "invalid token", http.StatusUnauthorized)
+            return
+        }
+        // disabled accounts and tokens issued before a password change, reset or forced
+        // logout are refused; if the check can't be made, refuse rather than let a
+        // revoked token through
+        if reason, err := sessionRejected(r.Context(), claims); err != nil {
+            logging.FromContext(r.Context()).Error("session check failed", "user_id", claims.UserID, "error", err)
+            http.Error(w, "session check failed", http.StatusServiceUnavailable)
+            return
+        } else if reason != "" {
+            http.Error(w, reason, http.StatusUnauthorized)
+            return
+        }
+        // admins must have passed two-factor authentication for every route but enrolling
+        if needsTwoFactor(claims, r) {
+            http.Error(w, "two-factor authentication required: enroll at /api/me/2fa and log in again", http.StatusForbidden)
+            return
+        }
         // attach to context
-        ctx := context.WithValue(r.Context(), "claims", claims)
+        ctx := context.WithValue(database.WithTenant(r.Context(), claims.TenantID), "claims", claims)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+)
+
+// resetQueueSize bounds the reset requests waiting to be sent; more are dropped
+const resetQueueSize = 100
+
+// resetRequest is a reset asked for by a caller who has already been answered.
+// logger carries the request's id into the worker's log lines.
+type resetRequest struct {
+	logger   *slog.Logger
+	username string
+}
+
+var (
+	resetMu    sync.Mutex
+	resetQueue chan resetRequest
+)
+
+// ResetLink is what the reset message gives the user: the configured reset page
+// with the token appended, or the bare token if no page is configured.
+func ResetLink(token string) string {
+	if resetURL == "" {
+		return token
+	}
+	u, err := url.Parse(resetURL)
+	if err != nil {
+		return token
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// StartPasswordResets runs the worker that sends queued password resets under bg.
+// Until it is called, RequestPasswordReset drops every request.
+func StartPasswordResets(bg *background.Manager) error {
+	q := make(chan resetRequest, resetQueueSize)
+	resetMu.Lock()
+	resetQueue = q
+	resetMu.Unlock()
+	return bg.Go(func(ctx context.Context) {
+		for {
+			select {
+			case req := <-q:
+				if err := sendPasswordReset(logging.WithContext(ctx, req.logger), req.username); err != nil {
+					req.logger.Error("password reset request failed", "username", req.username, "error", err)
+				}
+			case <-bg.Stopping():
+				if n := len(q); n > 0 {
+					logging.FromContext(ctx).Warn("password resets dropped at shutdown", "count", n)
+				}
+				return
+			}
+		}
+	})
+}
+
+// RequestPasswordReset queues a reset for username and returns right away, so
+// neither its result nor how long it takes tells the caller whether the username
+// exists. It never blocks: when the queue is full the request is dropped.
+func RequestPasswordReset(ctx context.Context, username string) {
+	resetMu.Lock()
+	q := resetQueue
+	resetMu.Unlock()
+	if q == nil {
+		return
+	}
+	select {
+	case q <- resetRequest{logger: logging.FromContext(ctx), username: username}:
+	default:
+		logging.FromContext(ctx).Warn("password reset queue full, dropping", "username", username)
+	}
+}
+
+// sendPasswordReset issues a reset token for username and sends it to the user's
+// email, or their phone if they have no email. An unknown username is not an
+// error; neither is a disabled account, which gets no token.
+func sendPasswordReset(ctx context.Context, username string) error {
+	u, err := models.GetUserByUsername(database.WithSystem(ctx), username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ctx = database.WithTenant(ctx, u.TenantID)
+	if u.DisabledAt != nil || (u.Email == "" && u.Phone == "") {
+		return nil
+	}
+	token, err := models.CreatePasswordResetToken(ctx, u.ID, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	prefs, err := models.GetNotificationPrefs(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	return notify.PasswordReset(ctx, u, prefs.Locale, ResetLink(token))
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out
+// everywhere. It returns models.ErrInvalidResetToken for a bad token.
+func ResetPassword(ctx context.Context, token, password string) error {
+	_, err := models.ResetPassword(database.WithSystem(ctx), token, password)
+	return err
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+// RevokeSessions invalidates every token issued to userID in ctx's tenant up to
+// now, so a changed password logs out the user's other devices. A token issued
+// right after it stays valid.
+func RevokeSessions(ctx context.Context, userID int) error {
+	return models.RevokeSessions(ctx, userID)
+}
+
+// sessionRejected returns why claims' token may no longer be used: its user is
+// disabled or deleted, or its sessions were revoked after it was issued. It
+// returns "" if the token is fine. Postgres is the source of truth; the lookup
+// reads through the cache.
+func sessionRejected(ctx context.Context, claims *Claims) (string, error) {
+	if database.Pool == nil {
+		return "", nil
+	}
+	s, err := models.GetSession(database.WithTenant(ctx, claims.TenantID), claims.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "unknown user", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if s.Disabled {
+		return "account disabled", nil
+	}
+	if s.RevokedAt != nil && issuedAtMicro(claims) < s.RevokedAt.UnixMicro() {
+		return "session revoked", nil
+	}
+	return "", nil
+}
+
+// issuedAtMicro returns when claims' token was issued in unix microseconds. Tokens
+// from before iat_us count from the start of their iat second, so a revocation in
+// that second voids them.
+func issuedAtMicro(claims *Claims) int64 {
+	if claims.IssuedAtMicro != 0 {
+		return claims.IssuedAtMicro
+	}
+	if claims.IssuedAt == nil {
+		return 0
+	}
+	return claims.IssuedAt.Unix() * 1e6
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+var (
+	// ErrInvalidCode means a two-factor or recovery code didn't match
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// ErrNoTwoFactor means the user hasn't enabled two-factor authentication
+	ErrNoTwoFactor = errors.New("two-factor authentication is not enabled")
+	// ErrChallengeExpired means a login challenge is unknown, expired, used up or
+	// already completed
+	ErrChallengeExpired = errors.New("login challenge expired; log in again")
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+	// challengeTTL is how long the second login step may take
+	challengeTTL = 5 * time.Minute
+	// challengeAttempts is how many codes may be tried against one challenge
+	challengeAttempts = 5
+)
+
+// BeginEnrollment gives u a new pending TOTP secret and returns it with its
+// provisioning URI. Two-factor isn't on until ConfirmEnrollment.
+func BeginEnrollment(ctx context.Context, u *models.User) (secret, uri string, err error) {
+	secret, err = NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := models.SetPendingTOTP(ctx, u.ID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(totpIssuer, u.Username, secret), nil
+}
+
+// ConfirmEnrollment switches two-factor on once the user proves their app has the
+// pending secret, and returns their recovery codes. The codes are only ever shown
+// here and by RegenerateRecoveryCodes.
+func ConfirmEnrollment(ctx context.Context, userID int, code string) ([]string, error) {
+	t, err := models.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, models.ErrTOTPEnabled
+	}
+	if t.Secret == "" {
+		return nil, ErrNoTwoFactor
+	}
+	step, ok := CheckTOTP(t.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	ok, err = models.EnableTOTP(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// a concurrent confirmation won, or the code was just used
+		return nil, ErrInvalidCode
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor checks a TOTP code or a recovery code for a user with
+// two-factor enabled and spends it, so neither works twice.
+func VerifySecondFactor(ctx context.Context, userID int, code string) error {
+	t, err := models.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !t.Enabled {
+		return ErrNoTwoFactor
+	}
+	code = strings.TrimSpace(code)
+	var ok bool
+	if len(code) == totpDigits {
+		step, valid := CheckTOTP(t.Secret, code, time.Now())
+		if !valid || step <= t.LastStep {
+			return ErrInvalidCode
+		}
+		ok, err = models.UseTOTPStep(ctx, userID, step)
+	} else {
+		ok, err = models.UseRecoveryCode(ctx, userID, models.HashAPIKey(normalizeRecoveryCode(code)))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// DisableTwoFactor switches two-factor off after checking a current code.
+func DisableTwoFactor(ctx context.Context, userID int, code string) error {
+	if err := VerifySecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+	return models.DisableTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a
+// current code, and returns the new ones.
+func RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := VerifySecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := models.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCodes returns recovery codes as shown to the user, XXXX-XXXX-XXXX-XXXX
+// with 80 random bits each, and the hashes to store.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := totpEncoding.EncodeToString(b)
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, models.HashAPIKey(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts a code with or without dashes, in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// challengeKey is the Redis hash holding a login challenge; only the token's hash
+// is used, so Redis contents can't complete a login
+func challengeKey(token string) string {
+	return "auth:mfa:" + models.HashAPIKey(token)
+}
+
+// StartLoginChallenge is the first login step for a user with two-factor enabled:
+// it returns a short-lived token that CompleteLoginChallenge exchanges, together
+// with a code, for the user. The token is not a session and opens no routes.
+func StartLoginChallenge(ctx context.Context, u *models.User) (string, error) {
+	if database.Rdb == nil {
+		return "", errors.New("redis not initialized")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := totpEncoding.EncodeToString(b)
+	key := challengeKey(token)
+	pipe := database.Rdb.TxPipeline()
+	pipe.HSet(ctx, key, "user", u.ID, "tenant", u.TenantID, "attempts", 0)
+	pipe.Expire(ctx, key, challengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteLoginChallenge checks code against the challenge's user and returns the
+// user. A challenge allows challengeAttempts codes and completes only once.
+func CompleteLoginChallenge(ctx context.Context, token, code string) (*models.User, error) {
+	if database.Rdb == nil {
+		return nil, errors.New("redis not initialized")
+	}
+	key := challengeKey(token)
+	n, err := database.Rdb.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, err := database.Rdb.HMGet(ctx, key, "user", "tenant").Result()
+	if err != nil {
+		return nil, err
+	}
+	userID, err1 := strconv.Atoi(asString(vals[0]))
+	tenantID, err2 := strconv.Atoi(asString(vals[1]))
+	if err1 != nil || err2 != nil || n > challengeAttempts {
+		// unknown tokens leave a bare counter behind; remove it along with used-up challenges
+		database.Rdb.Del(ctx, key)
+		return nil, ErrChallengeExpired
+	}
+	ctx = database.WithTenant(ctx, tenantID)
+	if err := VerifySecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	// only the caller that removes the challenge completes it
+	if n, err := database.Rdb.Del(ctx, key).Result(); err != nil || n == 0 {
+		return nil, ErrChallengeExpired
+	}
+	return models.GetUserByID(ctx, userID)
+}
+
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// twoFactorSetupPaths are the routes an admin session that didn't pass two-factor
+// authentication may use: enough to enroll and get a token that did
+var twoFactorSetupPaths = map[string]bool{
+	"/api/me/2fa":         true,
+	"/api/me/2fa/enroll":  true,
+	"/api/me/2fa/confirm": true,
+}
+
+// needsTwoFactor reports whether claims are an admin's from a password-only login
+// and r isn't one of the routes that let them enroll
+func needsTwoFactor(claims *Claims, r *http.Request) bool {
+	return claims.Role == "admin" && !claims.MFA && !twoFactorSetupPaths[r.URL.Path]
+}
@@ -0,0 +1,123 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// abortGrace is how long Shutdown waits for workers after cancelling the root context
+const abortGrace = time.Second
+
+// ErrShuttingDown is returned by Go once Shutdown has started
+var ErrShuttingDown = errors.New("shutting down")
+
+type closer struct {
+	name string
+	fn   func()
+}
+
+// Manager owns the root context for background workers. Workers started with Go
+// are tracked so Shutdown can wait for them before closing shared resources.
+//
+// Shutdown happens in three steps:
+//  1. Stopping() is closed and Go refuses new work; workers should finish the
+//     step they are in and return.
+//  2. Shutdown waits for workers until its context expires, then cancels the
+//     root context to abort whatever is still running.
+//  3. Closers registered with OnClose run in reverse registration order.
+type Manager struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	wg      sync.WaitGroup
+	closers []closer
+}
+
+// New returns a Manager with a fresh root context.
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, stopping: make(chan struct{})}
+}
+
+// Context is the root context for background work. It is cancelled only after
+// the drain deadline passes, so in-flight database writes can complete.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Stopping is closed when Shutdown starts.
+func (m *Manager) Stopping() <-chan struct{} {
+	return m.stopping
+}
+
+// Go runs fn in a tracked goroutine with the root context.
+func (m *Manager) Go(fn func(ctx context.Context)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrShuttingDown
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		fn(m.ctx)
+	}()
+	return nil
+}
+
+// OnClose registers fn to run at the end of Shutdown. Closers run in reverse
+// order, like defers, so register dependencies before their users.
+func (m *Manager) OnClose(name string, fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closers = append(m.closers, closer{name: name, fn: fn})
+}
+
+// Shutdown stops accepting work, waits for running workers until ctx is done,
+// cancels the root context and runs the closers. It returns ctx.Err() if workers
+// had to be aborted.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stopping)
+	m.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		slog.Warn("background: drain deadline exceeded, aborting workers", "error", err)
+	}
+	m.cancel()
+	if err != nil {
+		// give aborted workers a moment to observe cancellation before closing pools
+		select {
+		case <-drained:
+		case <-time.After(abortGrace):
+		}
+	}
+
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		slog.Info("background: closing", "resource", c.name)
+		c.fn()
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+// Package cache is a Redis read-through cache for model lookups.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// Every cached key has a version, bumped by Invalidate. A load reads the version
+// before it reads Postgres and only stores its result if the version hasn't moved
+// since, so a load that raced an invalidation can't put the old row back.
+//
+//	<key>        the cached value, expires after the configured TTL
+//	<key>:v      the version, expires versionTTL after the last invalidation
+const versionTTL = 24 * time.Hour
+
+func versionKey(key string) string { return key + ":v" }
+
+// setIfVersion stores ARGV[2] under KEYS[1] for ARGV[3] ms if the version at
+// KEYS[2] is still ARGV[1] ("" for none)
+var setIfVersion = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '') ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+var (
+	mu  sync.RWMutex
+	cfg config.CacheConfig
+
+	// flights collapses concurrent misses for the same key into one load, so an
+	// expired hot key doesn't send every waiting request to Postgres at once
+	flights singleflight.Group
+)
+
+// Configure sets the TTL and the kill switch. Until it is called caching is off.
+func Configure(c config.CacheConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+func current() config.CacheConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg
+}
+
+// Fetch returns the value cached under key, or calls load and caches its result
+// for the configured TTL. name labels the hit/miss metrics. Load errors are not
+// cached. When caching is disabled or Redis fails, load is called directly.
+func Fetch[T any](ctx context.Context, name, key string, load func(context.Context) (T, error)) (T, error) {
+	c := current()
+	if !c.Enabled || database.Rdb == nil {
+		return load(ctx)
+	}
+	logger := logging.FromContext(ctx)
+
+	b, err := database.Rdb.Get(ctx, key).Bytes()
+	if err == nil {
+		var v T
+		if err := json.Unmarshal(b, &v); err == nil {
+			metrics.ObserveCache(name, "hit")
+			return v, nil
+		}
+		logger.Warn("cache entry undecodable, reloading", "cache", name, "key", key)
+	} else if !errors.Is(err, redis.Nil) {
+		metrics.ObserveCache(name, "error")
+		logger.Warn("cache read failed", "cache", name, "error", err)
+		return load(ctx)
+	}
+	metrics.ObserveCache(name, "miss")
+
+	// the shared load must not be cut short by whichever caller happened to start it
+	v, err, _ := flights.Do(key, func() (interface{}, error) {
+		lctx := context.WithoutCancel(ctx)
+		version, err := database.Rdb.Get(lctx, versionKey(key)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			// without the version the result can't be stored safely
+			logger.Warn("cache read failed", "cache", name, "error", err)
+			return load(lctx)
+		}
+		v, err := load(lctx)
+		if err != nil {
+			return v, err
+		}
+		if b, err := json.Marshal(v); err == nil {
+			err := setIfVersion.Run(lctx, database.Rdb, []string{key, versionKey(key)}, version, b, c.TTL.Milliseconds()).Err()
+			if err != nil {
+				logger.Warn("cache write failed", "cache", name, "error", err)
+			}
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// Invalidate deletes keys and bumps their versions, so loads still in flight don't
+// store what they read. It runs even when caching is disabled so entries written
+// before the kill switch was flipped don't come back stale when it is flipped again.
+// A failure is logged and returned for callers that can't serve a stale entry
+// until it expires.
+func Invalidate(ctx context.Context, keys ...string) error {
+	if database.Rdb == nil || len(keys) == 0 {
+		return nil
+	}
+	// any in-flight load for these keys may have read the old row; later callers
+	// start their own
+	for _, k := range keys {
+		flights.Forget(k)
+	}
+	_, err := database.Rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, k := range keys {
+			p.Incr(ctx, versionKey(k))
+			p.Expire(ctx, versionKey(k), versionTTL)
+		}
+		p.Del(ctx, keys...)
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("cache invalidation failed", "keys", keys, "error", err)
+	}
+	return err
+}
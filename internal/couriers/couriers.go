@@ -0,0 +1,225 @@
+// Package couriers assigns orders to couriers and keeps each courier's planned
+// route current: the route is replanned whenever an order is assigned to or taken
+// off a courier, and shortly after one of their orders changes status. The
+// delivery estimates of the orders involved (see internal/eta) are refreshed along
+// with it.
+package couriers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/eta"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/routing"
+)
+
+// courierKey identifies a courier across tenants
+type courierKey struct {
+	tenantID int
+	id       int
+}
+
+var (
+	mu      sync.RWMutex
+	cfg     = config.Default().Routing
+	workers *background.Manager
+
+	// pending holds the couriers with a replan scheduled and not yet started
+	pendingMu sync.Mutex
+	pending   = make(map[courierKey]bool)
+)
+
+// Configure sets how routes are planned and the background manager delayed
+// replans run under. Without a manager they run right away.
+func Configure(c config.RoutingConfig, bg *background.Manager) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	workers = bg
+}
+
+func current() (config.RoutingConfig, *background.Manager) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg, workers
+}
+
+// Options returns the routing options for a courier at pos (nil if unknown)
+// setting off at start
+func Options(pos *geo.Point, start time.Time) routing.Options {
+	c, _ := current()
+	return routing.Options{Start: pos, At: start, Speed: float64(c.SpeedKMH) / 3.6, StopTime: c.StopTime}
+}
+
+// Stops returns the stops still ahead for orders: the pickup of orders not yet in
+// transit and every drop-off. Drop-offs are windowed by the order's delivery
+// window. Orders without positions, or already finished, have no stops.
+func Stops(orders []*models.Order) []routing.Stop {
+	var stops []routing.Stop
+	for _, o := range orders {
+		if o.Pickup == nil || o.Dropoff == nil || o.Status == "delivered" || o.Status == "cancelled" {
+			continue
+		}
+		if o.Status != "in_transit" {
+			stops = append(stops, routing.Stop{OrderID: o.ID, Kind: routing.Pickup, Position: *o.Pickup})
+		}
+		stops = append(stops, routing.Stop{OrderID: o.ID, Kind: routing.Dropoff, Position: *o.Dropoff,
+			Earliest: o.DeliverAfter, Latest: o.DeliverBefore})
+	}
+	return stops
+}
+
+// Replan plans the route of a courier in ctx's tenant from their last known
+// position, stores it and refreshes the ETAs of the orders on it. The route is
+// planned outside any transaction; if a replan that started later has already
+// stored its route, that one is kept and returned. It returns pgx.ErrNoRows if id
+// is not a courier.
+func Replan(ctx context.Context, id int) (*models.Courier, error) {
+	c, assigned, readAt, err := models.CourierPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	opts := Options(c.Position, now)
+	route, err := plan(ctx, id, assigned, opts)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := models.SetCourierRoute(ctx, id, route, readAt)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		// the later replan refreshed the ETAs from its own route
+		return models.GetCourier(ctx, id)
+	}
+	c.Route = route
+	eta.RefreshRoute(ctx, c, opts, assigned)
+	return c, nil
+}
+
+// plan orders the stops of a courier's orders. Assignment keeps a courier within
+// routing.max_orders; should they have more, e.g. after the limit was lowered, the
+// stops are visited oldest order first rather than optimised.
+func plan(ctx context.Context, id int, orders []*models.Order, opts routing.Options) (*routing.Route, error) {
+	c, _ := current()
+	if len(orders) > c.MaxOrders {
+		logging.FromContext(ctx).Warn("couriers: too many orders to optimise the route", "courier_id", id, "orders", len(orders), "max", c.MaxOrders)
+		return routing.Follow(Stops(orders), opts)
+	}
+	return routing.Plan(Stops(orders), opts)
+}
+
+// Moved refreshes the ETAs of a courier's orders after they reported a new
+// position. The route itself is kept; it is retimed from the position. Failures
+// are logged.
+func Moved(ctx context.Context, id int) {
+	c, err := models.GetCourier(ctx, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("couriers: failed to load courier", "courier_id", id, "error", err)
+		return
+	}
+	orders, err := models.CourierOrders(ctx, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("couriers: failed to load orders", "courier_id", id, "error", err)
+		return
+	}
+	eta.RefreshRoute(ctx, c, Options(c.Position, time.Now().UTC()), orders)
+}
+
+// Assign assigns an order to a courier, or unassigns it if courierID is nil (see
+// models.AssignCourier), replans the routes of the couriers it moved between and
+// returns the order with its new ETA.
+func Assign(ctx context.Context, orderID int, courierID *int) (*models.Order, error) {
+	c, _ := current()
+	o, previous, err := models.AssignCourier(ctx, orderID, courierID, c.MaxOrders)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && (courierID == nil || *previous != *courierID) {
+		replan(ctx, *previous)
+	}
+	if courierID == nil {
+		eta.Refresh(ctx, o)
+		return o, nil
+	}
+	replan(ctx, *courierID)
+	// the replan refreshed the order's ETA
+	if fresh, err := models.GetOrderByID(ctx, orderID); err == nil {
+		o = fresh
+	}
+	return o, nil
+}
+
+// OrderChanged schedules a replan of the route of the courier an order is assigned
+// to after its status changed: a pickup is done once the order is in transit, and
+// finished orders leave the route. The ETA of an order that is finished, or has no
+// courier, is refreshed on its own. Failures are logged; the next change replans
+// again.
+func OrderChanged(ctx context.Context, orderID int) {
+	o, err := models.GetOrderByID(ctx, orderID)
+	if err != nil {
+		logging.FromContext(ctx).Error("couriers: failed to load order", "order_id", orderID, "error", err)
+		return
+	}
+	if o.CourierID != nil {
+		scheduleReplan(ctx, *o.CourierID)
+	}
+	if o.CourierID == nil || o.Status == "delivered" || o.Status == "cancelled" {
+		eta.Refresh(ctx, o)
+	}
+}
+
+// scheduleReplan replans a courier in ctx's tenant after routing.replan_delay in
+// the background. Changes to the courier's orders until then share the replan; a
+// change after it started schedules another.
+func scheduleReplan(ctx context.Context, id int) {
+	c, bg := current()
+	if bg == nil {
+		replan(ctx, id)
+		return
+	}
+	tenantID, _ := database.TenantID(ctx)
+	key := courierKey{tenantID: tenantID, id: id}
+	pendingMu.Lock()
+	if pending[key] {
+		pendingMu.Unlock()
+		return
+	}
+	pending[key] = true
+	pendingMu.Unlock()
+
+	logger := logging.FromContext(ctx)
+	err := bg.Go(func(bctx context.Context) {
+		t := time.NewTimer(c.ReplanDelay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-bg.Stopping():
+		}
+		pendingMu.Lock()
+		delete(pending, key)
+		pendingMu.Unlock()
+		replan(logging.WithContext(database.CopyScope(bctx, ctx), logger), id)
+	})
+	if err != nil {
+		// shutting down; the route is replanned with the next change
+		pendingMu.Lock()
+		delete(pending, key)
+		pendingMu.Unlock()
+	}
+}
+
+// replan is Replan with failures logged
+func replan(ctx context.Context, id int) {
+	if _, err := Replan(ctx, id); err != nil {
+		logging.FromContext(ctx).Error("couriers: failed to replan route", "courier_id", id, "error", err)
+	}
+}
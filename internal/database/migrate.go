@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// migrationLockID is the pg advisory lock key held while migrating, so replicas
+// starting at the same time don't apply the same migration twice
+const migrationLockID = 72_001
+
+type migration struct {
+	version int
+	name    string
+}
+
+// listMigrations returns the NNNN_name.sql files in fsys sorted by version.
+func listMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	var res []migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		v, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", e.Name())
+		}
+		if prev, ok := seen[v]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", prev, e.Name(), v)
+		}
+		seen[v] = e.Name()
+		res = append(res, migration{version: v, name: e.Name()})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].version < res[j].version })
+	return res, nil
+}
+
+// LatestMigration returns the highest migration version in fsys.
+func LatestMigration(fsys fs.FS) (int, error) {
+	ms, err := listMigrations(fsys)
+	if err != nil || len(ms) == 0 {
+		return 0, err
+	}
+	return ms[len(ms)-1].version, nil
+}
+
+// Migrate applies every migration in fsys newer than the recorded schema version,
+// each in its own transaction, and returns the resulting version.
+func Migrate(ctx context.Context, fsys fs.FS) (int, error) {
+	ms, err := listMigrations(fsys)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := Pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return 0, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	)`); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return 0, err
+	}
+
+	for _, m := range ms {
+		if m.version <= current {
+			continue
+		}
+		sqlBytes, err := fs.ReadFile(fsys, m.name)
+		if err != nil {
+			return current, fmt.Errorf("failed to read migration %s: %w", m.name, err)
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			// migrations may rewrite rows in any tenant
+			if _, err := tx.Exec(ctx, "SELECT set_config('app.system', 'on', true)"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, string(sqlBytes)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return current, fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		current = m.version
+	}
+	return current, nil
+}
+
+// SchemaVersion returns the highest applied migration version.
+func SchemaVersion(ctx context.Context) (int, error) {
+	var v int
+	err := Pool.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&v)
+	return v, err
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/tracing"
+)
+
+var Rdb *redis.Client
+
+// InitRedis creates the Redis client and checks the connection
+func InitRedis(ctx context.Context, rc config.RedisConfig) error {
+	Rdb = redis.NewClient(&redis.Options{
+		Addr:        rc.Addr,
+		Password:    rc.Password,
+		DB:          rc.DB,
+		DialTimeout: rc.DialTimeout,
+	})
+	Rdb.AddHook(tracing.RedisHook{})
+	return Rdb.Ping(ctx).Err()
+}
+
+// PingRedis checks that the Redis server is reachable
+func PingRedis(ctx context.Context) error {
+	if Rdb == nil {
+		return errors.New("redis not initialized")
+	}
+	return Rdb.Ping(ctx).Err()
+}
+
+// CloseRedis closes the Redis client
+func CloseRedis() {
+	if Rdb != nil {
+		_ = Rdb.Close()
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNoScope is returned by Scoped when the context carries neither a tenant nor a
+// system scope. Queries never fall back to seeing every tenant's rows.
+var ErrNoScope = errors.New("no tenant scope in context")
+
+type scopeKey struct{}
+
+type scope struct {
+	tenantID int
+	system   bool
+}
+
+// WithTenant restricts queries run under ctx to one merchant's rows.
+func WithTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{tenantID: tenantID})
+}
+
+// WithSystem lets queries run under ctx see every tenant. Use it only for work that
+// is not on behalf of a single tenant: login lookups, startup recovery, workers.
+func WithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{system: true})
+}
+
+// TenantID returns the tenant ctx is scoped to; ok is false for system or unscoped contexts.
+func TenantID(ctx context.Context) (id int, ok bool) {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.tenantID, s.tenantID != 0
+}
+
+// TenantFilter returns the tenant id to filter on, or nil under a system scope,
+// for use in queries of the form `($n::int IS NULL OR tenant_id = $n)`.
+func TenantFilter(ctx context.Context) interface{} {
+	if id, ok := TenantID(ctx); ok {
+		return id
+	}
+	return nil
+}
+
+// CopyScope returns dst carrying the tenant scope of src, for work that outlives
+// the request that started it.
+func CopyScope(dst, src context.Context) context.Context {
+	if s, ok := src.Value(scopeKey{}).(scope); ok {
+		return context.WithValue(dst, scopeKey{}, s)
+	}
+	return dst
+}
+
+// Scoped runs fn in a transaction with the row-level security settings for ctx's
+// scope (app.tenant_id or app.system), so the database enforces the same isolation
+// as the WHERE clauses in internal/models.
+func Scoped(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	s, ok := ctx.Value(scopeKey{}).(scope)
+	if !ok || (!s.system && s.tenantID == 0) {
+		return ErrNoScope
+	}
+	return pgx.BeginFunc(ctx, Pool, func(tx pgx.Tx) error {
+		var err error
+		if s.system {
+			_, err = tx.Exec(ctx, "SELECT set_config('app.system', 'on', true)")
+		} else {
+			_, err = tx.Exec(ctx, "SELECT set_config('app.tenant_id', $1, true)", strconv.Itoa(s.tenantID))
+		}
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,298 @@
+// Package eta estimates when orders will be delivered. An order on a courier's
+// route is timed along that route from the courier's last known position. Other
+// orders are timed from how long delivered orders in the same service zone spent
+// in each status. Estimates are stored on the order, and published on the Redis
+// channel orders:updates, whenever they move noticeably.
+package eta
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/routing"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// minShift and minConfidenceShift are how far an estimate has to move before
+	// it is stored and published again
+	minShift           = time.Minute
+	minConfidenceShift = 0.1
+
+	// routeConfidence is the confidence in a route timed from a fresh position. It
+	// halves for every positionHalfLife since the position was reported, and drops
+	// by stopFactor for every stop ahead of the drop-off.
+	routeConfidence  = 0.9
+	positionHalfLife = 15 * time.Minute
+	stopFactor       = 0.95
+	// plannedConfidence replaces routeConfidence for routes planned before the
+	// courier reported any position
+	plannedConfidence = 0.5
+
+	// historyConfidence is approached as a zone's delivered orders grow; averages
+	// over the whole merchant count for merchantFactor of that
+	historyConfidence = 0.6
+	merchantFactor    = 0.5
+)
+
+// historyEntry is a tenant's order history and when it was loaded
+type historyEntry struct {
+	history  *models.TransitionHistory
+	loadedAt time.Time
+}
+
+var (
+	mu        sync.RWMutex
+	cfg       = config.Default().ETA
+	histories = make(map[int]historyEntry)
+	// loads shares one history query between the refreshes of a tenant
+	loads singleflight.Group
+)
+
+// Configure sets how much order history estimates are drawn from and how long it
+// is reused. Histories loaded before are dropped.
+func Configure(c config.ETAConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	histories = make(map[int]historyEntry)
+}
+
+// orderHistory returns the order history of ctx's tenant as of now, reusing one
+// loaded less than eta.history_ttl ago. Load errors are not kept.
+func orderHistory(ctx context.Context, c config.ETAConfig, now time.Time) (*models.TransitionHistory, error) {
+	// a system scope averages over every tenant and is kept under 0
+	tenantID, _ := database.TenantID(ctx)
+	mu.RLock()
+	e, ok := histories[tenantID]
+	mu.RUnlock()
+	if ok && now.Sub(e.loadedAt) < c.HistoryTTL {
+		return e.history, nil
+	}
+	v, err, _ := loads.Do(strconv.Itoa(tenantID), func() (interface{}, error) {
+		h, err := models.OrderTransitions(context.WithoutCancel(ctx), now.Add(-c.History))
+		if err != nil {
+			return nil, err
+		}
+		if c.HistoryTTL > 0 {
+			mu.Lock()
+			histories[tenantID] = historyEntry{history: h, loadedAt: now}
+			mu.Unlock()
+		}
+		return h, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*models.TransitionHistory), nil
+}
+
+// Estimator estimates delivery times as of Now
+type Estimator struct {
+	Now time.Time
+	// Courier is the courier whose orders are estimated, with their route, and
+	// Options times the route from their position (see couriers.Options). Courier
+	// is nil for orders without one.
+	Courier *models.Courier
+	Options routing.Options
+	// History is what orders that aren't on Courier's route are estimated from
+	History *models.TransitionHistory
+	// MinSamples is how many delivered orders a zone needs for its own averages
+	// to be used instead of the merchant's
+	MinSamples int
+}
+
+// Estimate returns when o is expected to be delivered, or nil if that can't be
+// told, and the confidence in it from 0 to 1. A delivered order's estimate is its
+// delivery time. An overdue order is expected now, with half the confidence, and
+// no order is expected before its delivery window opens.
+func (e *Estimator) Estimate(o *models.Order) (*time.Time, float64) {
+	switch o.Status {
+	case "cancelled":
+		return nil, 0
+	case "delivered":
+		at := o.UpdatedAt
+		if o.DeliveredAt != nil {
+			at = *o.DeliveredAt
+		}
+		return &at, 1
+	}
+	at, confidence, ok := e.fromRoute(o)
+	if !ok {
+		at, confidence, ok = e.fromHistory(o)
+	}
+	if !ok {
+		return nil, 0
+	}
+	if at.Before(e.Now) {
+		at, confidence = e.Now, confidence/2
+	}
+	if o.DeliverAfter != nil && at.Before(*o.DeliverAfter) {
+		at = *o.DeliverAfter
+	}
+	at = at.Truncate(time.Second)
+	return &at, math.Round(confidence*100) / 100
+}
+
+// dropoff returns the index of o's drop-off on the courier's route, or -1
+func (e *Estimator) dropoff(o *models.Order) int {
+	c := e.Courier
+	if c == nil || c.Route == nil || o.CourierID == nil || *o.CourierID != c.UserID {
+		return -1
+	}
+	for i, s := range c.Route.Stops {
+		if s.OrderID == o.ID && s.Kind == routing.Dropoff {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Estimator) fromRoute(o *models.Order) (time.Time, float64, bool) {
+	k := e.dropoff(o)
+	if k < 0 {
+		return time.Time{}, 0, false
+	}
+	c := e.Courier
+	factor := math.Pow(stopFactor, float64(k))
+	if e.Options.Start == nil || c.PositionAt == nil {
+		// timed from the first stop when the route was planned
+		return c.Route.Stops[k].Arrival, plannedConfidence * factor, true
+	}
+	stops := make([]routing.Stop, k+1)
+	for i := range stops {
+		stops[i] = c.Route.Stops[i].Stop
+	}
+	r, err := routing.Follow(stops, e.Options)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	age := e.Now.Sub(*c.PositionAt)
+	if age < 0 {
+		age = 0
+	}
+	fresh := math.Pow(0.5, float64(age)/float64(positionHalfLife))
+	return r.Stops[k].Arrival, routeConfidence * fresh * factor, true
+}
+
+func (e *Estimator) fromHistory(o *models.Order) (time.Time, float64, bool) {
+	if e.History == nil {
+		return time.Time{}, 0, false
+	}
+	t, scale := e.History.Zone(o.ZoneID), 1.0
+	if t.Orders < e.MinSamples {
+		t, scale = e.History.All, merchantFactor
+	}
+	if t.Orders < e.MinSamples || t.Orders == 0 {
+		return time.Time{}, 0, false
+	}
+	remaining, ok := t.Remaining(o.Status)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	reached := o.CreatedAt
+	switch {
+	case o.Status == "dispatched" && o.DispatchedAt != nil:
+		reached = *o.DispatchedAt
+	case o.Status == "in_transit" && o.InTransitAt != nil:
+		reached = *o.InTransitAt
+	case o.Status != "created":
+		// reached before the timestamps were recorded; updated_at is the last change
+		reached = o.UpdatedAt
+	}
+	n := float64(t.Orders)
+	return reached.Add(remaining), historyConfidence * scale * n / (n + float64(e.MinSamples)), true
+}
+
+// needsHistory reports whether estimating o needs e.History
+func (e *Estimator) needsHistory(o *models.Order) bool {
+	return o.Status != "delivered" && o.Status != "cancelled" && e.dropoff(o) < 0
+}
+
+// Refresh re-estimates orders that aren't assigned to a courier, or are finished
+func Refresh(ctx context.Context, orders ...*models.Order) {
+	refresh(ctx, &Estimator{Now: time.Now().UTC()}, orders)
+}
+
+// RefreshRoute re-estimates the orders assigned to courier c from their route;
+// opts time it from the courier's position
+func RefreshRoute(ctx context.Context, c *models.Courier, opts routing.Options, orders []*models.Order) {
+	refresh(ctx, &Estimator{Now: opts.At, Courier: c, Options: opts}, orders)
+}
+
+// refresh estimates orders and stores, publishes and sets on the order every
+// estimate that moved. Failures are logged; the next change estimates again.
+func refresh(ctx context.Context, e *Estimator, orders []*models.Order) {
+	mu.RLock()
+	c := cfg
+	mu.RUnlock()
+	e.MinSamples = c.MinSamples
+	logger := logging.FromContext(ctx)
+	for _, o := range orders {
+		if e.History == nil && e.needsHistory(o) {
+			h, err := orderHistory(ctx, c, e.Now)
+			if err != nil {
+				logger.Error("eta: failed to load order history", "error", err)
+				h = &models.TransitionHistory{}
+			}
+			e.History = h
+		}
+		at, confidence := e.Estimate(o)
+		if !moved(o.ETA, o.ETAConfidence, at, confidence) {
+			continue
+		}
+		updated, err := models.SetOrderETA(ctx, o.ID, at, confidence)
+		if err != nil {
+			logger.Error("eta: failed to store estimate", "order_id", o.ID, "error", err)
+			continue
+		}
+		if updated == nil {
+			continue
+		}
+		*o = *updated
+		publish(ctx, o)
+	}
+}
+
+// moved reports whether an estimate differs enough from the previous one to be
+// stored and published
+func moved(prev *time.Time, prevConfidence float64, at *time.Time, confidence float64) bool {
+	if (prev == nil) != (at == nil) {
+		return true
+	}
+	if math.Abs(confidence-prevConfidence) >= minConfidenceShift {
+		return true
+	}
+	return at != nil && at.Sub(*prev).Abs() >= minShift
+}
+
+// update is the message published on orders:updates when an estimate moves. It
+// carries the status too, like the status change messages.
+type update struct {
+	OrderID       int        `json:"order_id"`
+	Status        string     `json:"status"`
+	ETA           *time.Time `json:"eta"`
+	ETAConfidence float64    `json:"eta_confidence"`
+}
+
+func publish(ctx context.Context, o *models.Order) {
+	if database.Rdb == nil {
+		return
+	}
+	payload, err := json.Marshal(update{OrderID: o.ID, Status: o.Status, ETA: o.ETA, ETAConfidence: o.ETAConfidence})
+	if err != nil {
+		return
+	}
+	if err := database.Rdb.Publish(ctx, "orders:updates", payload).Err(); err != nil {
+		logging.FromContext(ctx).Error("failed to publish order update",
+			"order_id", o.ID, "eta", o.ETA, "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package geo has the little geometry the service needs: points, polygons with
+// point-in-polygon tests for service zones, and great-circle distances. Polygons
+// are treated as flat in latitude/longitude, which is accurate enough at city
+// scale; they must not cross the antimeridian.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MaxVertices bounds the size of a polygon
+const MaxVertices = 500
+
+// earthRadius is the mean radius of the earth in metres
+const earthRadius = 6371000
+
+// Point is a WGS 84 position in degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid reports whether p is a position on earth
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180 &&
+		!math.IsNaN(p.Lat) && !math.IsNaN(p.Lng)
+}
+
+// Polygon is a simple polygon given by its vertices in order. The last vertex
+// connects back to the first; repeating the first vertex at the end is allowed.
+type Polygon []Point
+
+// ring returns the vertices without a closing repeat of the first
+func (pg Polygon) ring() Polygon {
+	if n := len(pg); n > 1 && pg[0] == pg[n-1] {
+		return pg[:n-1]
+	}
+	return pg
+}
+
+// Validate checks that pg is a usable zone boundary: at least three valid,
+// distinct vertices, no more than MaxVertices, no self-intersections and a
+// non-zero area.
+func (pg Polygon) Validate() error {
+	r := pg.ring()
+	if len(r) < 3 {
+		return errors.New("must have at least 3 vertices")
+	}
+	if len(r) > MaxVertices {
+		return fmt.Errorf("must have at most %d vertices", MaxVertices)
+	}
+	for i, p := range r {
+		if !p.Valid() {
+			return fmt.Errorf("vertex %d is not a valid position", i)
+		}
+		if p == r[(i+1)%len(r)] {
+			return fmt.Errorf("vertex %d repeats the one before it", (i+1)%len(r))
+		}
+	}
+	if r.area() == 0 {
+		return errors.New("must enclose an area")
+	}
+	n := len(r)
+	for i := 0; i < n; i++ {
+		a1, a2 := r[i], r[(i+1)%n]
+		// compare with every later edge that isn't adjacent
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			if segmentsIntersect(a1, a2, r[j], r[(j+1)%n]) {
+				return fmt.Errorf("edges %d and %d cross", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// area is the polygon's signed area in square degrees, by the shoelace formula
+func (pg Polygon) area() float64 {
+	var sum float64
+	for i, p := range pg {
+		q := pg[(i+1)%len(pg)]
+		sum += p.Lng*q.Lat - q.Lng*p.Lat
+	}
+	return sum / 2
+}
+
+// Contains reports whether p lies inside pg or on its boundary
+func (pg Polygon) Contains(p Point) bool {
+	r := pg.ring()
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if onSegment(a, b, p) {
+			return true
+		}
+		// cast a ray towards +lng and count the edges it crosses
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// cross is the z component of (b-a) x (c-a): positive if c is left of a->b
+func cross(a, b, c Point) float64 {
+	return (b.Lng-a.Lng)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lng-a.Lng)
+}
+
+// onSegment reports whether p lies on the segment a-b
+func onSegment(a, b, p Point) bool {
+	return cross(a, b, p) == 0 &&
+		math.Min(a.Lng, b.Lng) <= p.Lng && p.Lng <= math.Max(a.Lng, b.Lng) &&
+		math.Min(a.Lat, b.Lat) <= p.Lat && p.Lat <= math.Max(a.Lat, b.Lat)
+}
+
+// segmentsIntersect reports whether segments a-b and c-d share a point
+func segmentsIntersect(a, b, c, d Point) bool {
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return onSegment(c, d, a) || onSegment(c, d, b) || onSegment(a, b, c) || onSegment(a, b, d)
+}
+
+// Distance is the great-circle distance between a and b in metres
+func Distance(a, b Point) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLng := (b.Lng - a.Lng) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
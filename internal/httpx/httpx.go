@@ -0,0 +1,47 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Recorder wraps a ResponseWriter to capture the status code and body size
+// for middleware that reports on the response after the handler returns.
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+}
+
+// NewRecorder wraps w; Status defaults to 200 for handlers that never call WriteHeader.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	r.Status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// RouteTemplate returns the matched mux route template (e.g. /api/orders/{id}/cancel),
+// or fallback when the request did not match a route.
+func RouteTemplate(r *http.Request, fallback string) string {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,133 @@
+// Package lock implements Redis lease locks with fencing tokens, so work that must
+// run on one instance at a time can be coordinated across replicas.
+package lock
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+)
+
+// ErrNotAcquired is returned by Acquire when another owner holds the lease.
+var ErrNotAcquired = errors.New("lock held by another owner")
+
+// renew extends the lease only if we still hold it.
+var renew = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// release deletes the lease only if we still hold it.
+var release = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lease is a held lock. It renews itself every third of its TTL until Release is
+// called or a renewal finds the lease gone, at which point Lost is closed.
+type Lease struct {
+	key   string
+	token int64
+	ttl   time.Duration
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// Acquire takes the lease on key for ttl. Every acquisition of key gets a fencing
+// token greater than all earlier ones; writers pass it along so the store can reject
+// writes from a holder whose lease has expired without it noticing.
+func Acquire(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	if database.Rdb == nil {
+		return nil, errors.New("redis not initialized")
+	}
+	// the counter never expires, so tokens keep increasing across leases
+	token, err := database.Rdb.Incr(ctx, key+":fence").Result()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := database.Rdb.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	l := &Lease{
+		key:   key,
+		token: token,
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.keepAlive(logging.FromContext(ctx).With("lock", key))
+	return l, nil
+}
+
+// Token returns the fencing token of this lease.
+func (l *Lease) Token() int64 {
+	return l.token
+}
+
+// Lost is closed when the lease could not be renewed and may now be held by someone else.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops renewal and gives the lease up if it is still ours.
+func (l *Lease) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	return release.Run(ctx, database.Rdb, []string{l.key}, strconv.FormatInt(l.token, 10)).Err()
+}
+
+func (l *Lease) keepAlive(logger *slog.Logger) {
+	defer close(l.done)
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+			n, err := renew.Run(ctx, database.Rdb, []string{l.key}, strconv.FormatInt(l.token, 10), l.ttl.Milliseconds()).Int()
+			cancel()
+			switch {
+			case err == nil && n == 1:
+				renewed = now
+			case err == nil:
+				logger.Warn("lease lost to another owner")
+				l.markLost()
+				return
+			case now.Sub(renewed) >= l.ttl:
+				// Redis has been unreachable for a whole TTL; assume the lease expired
+				logger.Warn("lease expired while redis was unreachable", "error", err)
+				l.markLost()
+				return
+			default:
+				logger.Warn("lease renewal failed, retrying", "error", err)
+			}
+		}
+	}
+}
+
+func (l *Lease) markLost() {
+	l.lostOnce.Do(func() { close(l.lost) })
+}
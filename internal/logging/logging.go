@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/httpx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on every response
+const RequestIDHeader = "X-Request-ID"
+
+type ctxKey struct{}
+
+// Init builds the process logger and installs it as slog's default.
+// format is "json" or "text"; level is debug, info, warn or error.
+func Init(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want json or text)", format)
+	}
+	l := slog.New(h)
+	slog.SetDefault(l)
+	return l, nil
+}
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Middleware assigns a request ID (reusing a sane incoming X-Request-ID), stores a
+// request-scoped logger in the context and writes one access log line per request.
+// Install it after tracing.Middleware so the trace ID is available.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(reqID) {
+			reqID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, reqID)
+
+		l := slog.Default().With("request_id", reqID)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			l = l.With("trace_id", sc.TraceID().String())
+		}
+
+		rec := httpx.NewRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(WithContext(r.Context(), l)))
+
+		level := slog.LevelInfo
+		if rec.Status >= 500 {
+			level = slog.LevelError
+		}
+		l.LogAttrs(r.Context(), level, "http request",
+			slog.String("method", r.Method),
+			slog.String("route", httpx.RouteTemplate(r, "unmatched")),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.Status),
+			slog.Int("bytes", rec.Bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
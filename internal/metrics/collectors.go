@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+var (
+	pgAcquiredConns = prometheus.NewDesc("pgxpool_acquired_conns", "Connections currently checked out of the pool.", nil, nil)
+	pgIdleConns     = prometheus.NewDesc("pgxpool_idle_conns", "Idle connections in the pool.", nil, nil)
+	pgTotalConns    = prometheus.NewDesc("pgxpool_total_conns", "Total connections in the pool.", nil, nil)
+	pgMaxConns      = prometheus.NewDesc("pgxpool_max_conns", "Maximum pool size.", nil, nil)
+	pgAcquireCount  = prometheus.NewDesc("pgxpool_acquire_total", "Successful connection acquires.", nil, nil)
+	pgEmptyAcquire  = prometheus.NewDesc("pgxpool_empty_acquire_total", "Acquires that had to wait because the pool was empty.", nil, nil)
+	pgCanceledAcq   = prometheus.NewDesc("pgxpool_canceled_acquire_total", "Acquires cancelled by their context.", nil, nil)
+	pgAcquireSecs   = prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Cumulative time spent acquiring connections.", nil, nil)
+
+	redisHits       = prometheus.NewDesc("redis_pool_hits_total", "Times a free connection was found in the pool.", nil, nil)
+	redisMisses     = prometheus.NewDesc("redis_pool_misses_total", "Times a free connection was not found in the pool.", nil, nil)
+	redisTimeouts   = prometheus.NewDesc("redis_pool_timeouts_total", "Times a wait for a connection timed out.", nil, nil)
+	redisTotalConns = prometheus.NewDesc("redis_pool_total_conns", "Total connections in the pool.", nil, nil)
+	redisIdleConns  = prometheus.NewDesc("redis_pool_idle_conns", "Idle connections in the pool.", nil, nil)
+	redisStaleConns = prometheus.NewDesc("redis_pool_stale_conns_total", "Stale connections removed from the pool.", nil, nil)
+)
+
+// poolCollector reports database.Pool.Stat() at scrape time
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{pgAcquiredConns, pgIdleConns, pgTotalConns, pgMaxConns, pgAcquireCount, pgEmptyAcquire, pgCanceledAcq, pgAcquireSecs} {
+		ch <- d
+	}
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.Pool == nil {
+		return
+	}
+	s := database.Pool.Stat()
+	ch <- prometheus.MustNewConstMetric(pgAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pgIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pgTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(pgMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(pgAcquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgEmptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgCanceledAcq, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgAcquireSecs, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+// redisCollector reports database.Rdb.PoolStats() at scrape time
+type redisCollector struct{}
+
+func (redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{redisHits, redisMisses, redisTimeouts, redisTotalConns, redisIdleConns, redisStaleConns} {
+		ch <- d
+	}
+}
+
+func (redisCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.Rdb == nil {
+		return
+	}
+	s := database.Rdb.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisHits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(redisMisses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(redisTimeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisTotalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisIdleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(redisStaleConns, prometheus.CounterValue, float64(s.StaleConns))
+}
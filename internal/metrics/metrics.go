@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rajnish-012/delivery-management-system/internal/httpx"
+)
+
+var (
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	httpInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	// ActiveProgressions tracks running order progression goroutines.
+	ActiveProgressions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "orders_progressions_active",
+		Help: "Order progression goroutines currently running.",
+	})
+
+	orderTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_status_transitions_total",
+		Help: "Order status transitions by previous and new status.",
+	}, []string{"from", "to"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Read-through cache lookups by cache and result (hit, miss or error).",
+	}, []string{"cache", "result"})
+
+	notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifications_total",
+		Help: "Customer notifications by channel and result (sent, failed, deferred or dropped).",
+	}, []string{"channel", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(poolCollector{}, redisCollector{})
+}
+
+// Handler serves the Prometheus exposition format for the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveTransition counts an order moving from one status to another.
+// Use "none" as from when an order is first created.
+func ObserveTransition(from, to string) {
+	orderTransitions.WithLabelValues(from, to).Inc()
+}
+
+// ObserveCache counts one cache lookup.
+func ObserveCache(cache, result string) {
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// ObserveNotification counts one notification outcome.
+func ObserveNotification(channel, result string) {
+	notifications.WithLabelValues(channel, result).Inc()
+}
+
+// Middleware records request latency labelled with the matched mux route template,
+// so /api/orders/1/cancel and /api/orders/2/cancel share one series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := httpx.RouteTemplate(r, "unmatched")
+
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		rec := httpx.NewRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		httpDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).Observe(time.Since(start).Seconds())
+	})
+}
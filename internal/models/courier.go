@@ -0,0 +1,209 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+    "github.com/rajnish-012/delivery-management-system/internal/geo"
+    "github.com/rajnish-012/delivery-management-system/internal/routing"
+)
+
+var (
+    // ErrNotCourier means an order was assigned to a user who isn't an active courier
+    ErrNotCourier = errors.New("user is not a courier")
+    // ErrOrderFinished means a delivered or cancelled order was to be assigned
+    ErrOrderFinished = errors.New("order is already delivered or cancelled")
+    // ErrNoPositions means an order without pickup and drop-off positions was to be
+    // assigned; such orders can't be routed
+    ErrNoPositions = errors.New("order has no pickup and drop-off positions")
+    // ErrCourierFull means an order was assigned to a courier who already has as many
+    // unfinished orders as they can be given
+    ErrCourierFull = errors.New("courier has too many orders")
+)
+
+// Courier is a user with the courier role, with their last reported position and
+// the route planned for them. Both are nil until first set.
+type Courier struct {
+    UserID     int            `json:"user_id"`
+    TenantID   int            `json:"tenant_id"`
+    Username   string         `json:"username"`
+    Position   *geo.Point     `json:"position"`
+    PositionAt *time.Time     `json:"position_at"`
+    Route      *routing.Route `json:"route"`
+}
+
+const courierColumns = "u.id, u.tenant_id, u.username, c.lat, c.lng, c.position_at, c.route"
+
+// courierFrom joins couriers to users; users who are, or were, couriers match
+const courierFrom = " FROM users u LEFT JOIN couriers c ON c.user_id=u.id WHERE u.deleted_at IS NULL AND (u.role='courier' OR c.user_id IS NOT NULL)"
+
+func scanCourier(row pgx.Row) (*Courier, error) {
+    c := &Courier{}
+    var lat, lng *float64
+    if err := row.Scan(&c.UserID, &c.TenantID, &c.Username, &lat, &lng, &c.PositionAt, &c.Route); err != nil {
+        return nil, err
+    }
+    c.Position = point(lat, lng)
+    return c, nil
+}
+
+// GetCourier returns a courier in ctx's tenant. Users who were couriers before their
+// role changed are still found while their data is kept.
+func GetCourier(ctx context.Context, id int) (*Courier, error) {
+    var c *Courier
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        c, err = scanCourier(tx.QueryRow(ctx,
+            "SELECT "+courierColumns+courierFrom+" AND u.id=$1 AND ($2::int IS NULL OR u.tenant_id=$2)",
+            id, database.TenantFilter(ctx)))
+        return err
+    })
+    return c, err
+}
+
+// ListCouriers returns the couriers in ctx's tenant by id
+func ListCouriers(ctx context.Context) ([]*Courier, error) {
+    var res []*Courier
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx,
+            "SELECT "+courierColumns+courierFrom+" AND u.role='courier' AND ($1::int IS NULL OR u.tenant_id=$1) ORDER BY u.id",
+            database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            c, err := scanCourier(rows)
+            if err != nil {
+                return err
+            }
+            res = append(res, c)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
+// SetCourierPosition records where a courier in ctx's tenant is now
+func SetCourierPosition(ctx context.Context, id int, p geo.Point) error {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return database.ErrNoScope
+    }
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        _, err := tx.Exec(ctx, `INSERT INTO couriers (user_id, tenant_id, lat, lng, position_at) VALUES ($1,$2,$3,$4,now())
+            ON CONFLICT (user_id) DO UPDATE SET lat=EXCLUDED.lat, lng=EXCLUDED.lng, position_at=EXCLUDED.position_at`,
+            id, tenantID, p.Lat, p.Lng)
+        return err
+    })
+}
+
+// AssignCourier assigns an order to a courier, or unassigns it if courierID is nil,
+// and returns the order and the courier it was assigned to before. The order must
+// not be finished, to be assigned it needs both positions, and the courier may
+// have fewer than maxOrders other unfinished orders. It returns ErrOrderFinished,
+// ErrNoPositions, ErrNotCourier, ErrCourierFull, or pgx.ErrNoRows for an unknown
+// order.
+func AssignCourier(ctx context.Context, orderID int, courierID *int, maxOrders int) (o *Order, previous *int, err error) {
+    err = database.Scoped(ctx, func(tx pgx.Tx) error {
+        cur, err := scanOrder(tx.QueryRow(ctx,
+            "SELECT "+orderColumns+" FROM orders WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2) FOR UPDATE",
+            orderID, database.TenantFilter(ctx)))
+        if err != nil {
+            return err
+        }
+        if cur.Status == "delivered" || cur.Status == "cancelled" {
+            return ErrOrderFinished
+        }
+        if courierID != nil {
+            if cur.Pickup == nil || cur.Dropoff == nil {
+                return ErrNoPositions
+            }
+            // the courier's user row is locked so concurrent assignments count each other
+            var one int
+            err := tx.QueryRow(ctx,
+                "SELECT 1 FROM users WHERE id=$1 AND tenant_id=$2 AND role='courier' AND disabled_at IS NULL AND deleted_at IS NULL FOR UPDATE",
+                *courierID, cur.TenantID).Scan(&one)
+            if errors.Is(err, pgx.ErrNoRows) {
+                return ErrNotCourier
+            }
+            if err != nil {
+                return err
+            }
+            var n int
+            if err := tx.QueryRow(ctx,
+                "SELECT count(*) FROM orders WHERE courier_id=$1 AND id<>$2 AND status NOT IN ('delivered','cancelled')",
+                *courierID, orderID).Scan(&n); err != nil {
+                return err
+            }
+            if n >= maxOrders {
+                return ErrCourierFull
+            }
+        }
+        previous = cur.CourierID
+        // updated_at tracks status changes, so assignment leaves it alone
+        o, err = scanOrder(tx.QueryRow(ctx, "UPDATE orders SET courier_id=$1 WHERE id=$2 RETURNING "+orderColumns, courierID, orderID))
+        return err
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    invalidateOrder(ctx, o.TenantID, o.ID, o.CustomerID)
+    return o, previous, nil
+}
+
+// courierOrders selects the orders assigned to courier $1 that are neither
+// delivered nor cancelled, oldest first
+const courierOrders = "SELECT " + orderColumns + " FROM orders WHERE courier_id=$1 AND status NOT IN ('delivered','cancelled') AND ($2::int IS NULL OR tenant_id=$2) ORDER BY created_at, id"
+
+// CourierOrders returns the orders assigned to a courier in ctx's tenant that are
+// neither delivered nor cancelled, oldest first
+func CourierOrders(ctx context.Context, id int) ([]*Order, error) {
+    return queryOrders(ctx, courierOrders, id, database.TenantFilter(ctx))
+}
+
+// CourierPlan returns what a courier's route is planned from: the courier, their
+// assigned orders that are neither delivered nor cancelled, oldest first, and the
+// time they were read, to store the route with (see SetCourierRoute). Nothing is
+// locked. It returns pgx.ErrNoRows if id is not a courier in ctx's tenant.
+func CourierPlan(ctx context.Context, id int) (c *Courier, assigned []*Order, readAt time.Time, err error) {
+    err = database.Scoped(ctx, func(tx pgx.Tx) error {
+        if _, err := tx.Exec(ctx, `INSERT INTO couriers (user_id, tenant_id)
+            SELECT id, tenant_id FROM users WHERE id=$1 AND role='courier' AND deleted_at IS NULL AND ($2::int IS NULL OR tenant_id=$2)
+            ON CONFLICT (user_id) DO NOTHING`, id, database.TenantFilter(ctx)); err != nil {
+            return err
+        }
+        // read before the orders, so a change the orders miss was made after readAt
+        if err := tx.QueryRow(ctx, "SELECT clock_timestamp()").Scan(&readAt); err != nil {
+            return err
+        }
+        var err error
+        c, err = scanCourier(tx.QueryRow(ctx,
+            "SELECT "+courierColumns+" FROM users u JOIN couriers c ON c.user_id=u.id WHERE u.id=$1 AND u.deleted_at IS NULL AND ($2::int IS NULL OR u.tenant_id=$2)",
+            id, database.TenantFilter(ctx)))
+        if err != nil {
+            return err
+        }
+        assigned, err = queryOrdersTx(ctx, tx, courierOrders, id, database.TenantFilter(ctx))
+        return err
+    })
+    return c, assigned, readAt, err
+}
+
+// SetCourierRoute stores a route planned from what CourierPlan read at readAt. A
+// route planned from a later read is kept instead, since it saw every change this
+// one did; SetCourierRoute then returns false.
+func SetCourierRoute(ctx context.Context, id int, route *routing.Route, readAt time.Time) (bool, error) {
+    var stored bool
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        tag, err := tx.Exec(ctx,
+            "UPDATE couriers SET route=$1, route_planned_at=$2 WHERE user_id=$3 AND (route_planned_at IS NULL OR route_planned_at < $2) AND ($4::int IS NULL OR tenant_id=$4)",
+            route, readAt, id, database.TenantFilter(ctx))
+        stored = tag.RowsAffected() > 0
+        return err
+    })
+    return stored, err
+}
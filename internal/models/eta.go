@@ -0,0 +1,97 @@
+package models
+
+import (
+    "context"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// Transitions are the average times delivered orders spent in each status before
+// moving on to the next
+type Transitions struct {
+    // Orders is how many delivered orders the averages are over
+    Orders     int
+    Created    time.Duration
+    Dispatched time.Duration
+    InTransit  time.Duration
+}
+
+// Remaining returns the average time left until delivery for an order that has
+// just reached status, and false for statuses past delivery
+func (t Transitions) Remaining(status string) (time.Duration, bool) {
+    switch status {
+    case "created":
+        return t.Created + t.Dispatched + t.InTransit, true
+    case "dispatched":
+        return t.Dispatched + t.InTransit, true
+    case "in_transit":
+        return t.InTransit, true
+    }
+    return 0, false
+}
+
+// TransitionHistory holds the transitions of a merchant's delivered orders, per
+// service zone and over all of them
+type TransitionHistory struct {
+    Zones map[int]Transitions
+    All   Transitions
+}
+
+// Zone returns the transitions of a zone; orders outside any zone have none
+func (h *TransitionHistory) Zone(id *int) Transitions {
+    if id == nil {
+        return Transitions{}
+    }
+    return h.Zones[*id]
+}
+
+// OrderTransitions averages the time spent in each status over the orders in ctx's
+// tenant delivered since since. Only orders that went through every status, in
+// order, count; manual overrides can skip or repeat one.
+func OrderTransitions(ctx context.Context, since time.Time) (*TransitionHistory, error) {
+    h := &TransitionHistory{Zones: make(map[int]Transitions)}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, `SELECT GROUPING(zone_id) = 1, zone_id, count(*),
+                COALESCE(avg(extract(epoch FROM dispatched_at - created_at)), 0)::float8,
+                COALESCE(avg(extract(epoch FROM in_transit_at - dispatched_at)), 0)::float8,
+                COALESCE(avg(extract(epoch FROM delivered_at - in_transit_at)), 0)::float8
+            FROM orders
+            WHERE status='delivered' AND delivered_at >= $1 AND ($2::int IS NULL OR tenant_id=$2)
+                AND created_at <= dispatched_at AND dispatched_at <= in_transit_at AND in_transit_at <= delivered_at
+            GROUP BY GROUPING SETS ((zone_id), ())`,
+            since, database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var all bool
+            var zoneID *int
+            var t Transitions
+            var created, dispatched, inTransit float64
+            if err := rows.Scan(&all, &zoneID, &t.Orders, &created, &dispatched, &inTransit); err != nil {
+                return err
+            }
+            t.Created = seconds(created)
+            t.Dispatched = seconds(dispatched)
+            t.InTransit = seconds(inTransit)
+            switch {
+            case all:
+                h.All = t
+            case zoneID != nil:
+                h.Zones[*zoneID] = t
+            }
+        }
+        return rows.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return h, nil
+}
+
+func seconds(s float64) time.Duration {
+    return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// OperatorTenantID is the merchant created by the 0002 migration. It owns data from
+// before multi-tenancy, and its admins are the only ones who can create merchants.
+const OperatorTenantID = 1
+
+// APIKeyPrefix starts every merchant API key so leaked keys are easy to grep for
+const APIKeyPrefix = "dms_"
+
+type Merchant struct {
+    ID        int       `json:"id"`
+    Slug      string    `json:"slug"`
+    Name      string    `json:"name"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey is a stored merchant key; the plaintext is only returned once, at creation
+type APIKey struct {
+    ID         int        `json:"id"`
+    TenantID   int        `json:"tenant_id"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes"`
+    CreatedBy  *int       `json:"created_by,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+const apiKeyColumns = "id, tenant_id, name, prefix, scopes, created_by, created_at, expires_at, last_used_at, revoked_at"
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+    k := &APIKey{}
+    if err := row.Scan(&k.ID, &k.TenantID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedBy, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+        return nil, err
+    }
+    return k, nil
+}
+
+func scanMerchant(row pgx.Row) (*Merchant, error) {
+    m := &Merchant{}
+    if err := row.Scan(&m.ID, &m.Slug, &m.Name, &m.CreatedAt); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// CreateMerchant adds a tenant. merchants itself is not tenant-scoped.
+func CreateMerchant(ctx context.Context, slug, name string) (*Merchant, error) {
+    return scanMerchant(database.Pool.QueryRow(ctx,
+        "INSERT INTO merchants (slug, name) VALUES ($1,$2) RETURNING id, slug, name, created_at", slug, name))
+}
+
+func GetMerchantBySlug(ctx context.Context, slug string) (*Merchant, error) {
+    return scanMerchant(database.Pool.QueryRow(ctx,
+        "SELECT id, slug, name, created_at FROM merchants WHERE slug=$1", slug))
+}
+
+// HashAPIKey returns the stored form of a key. Keys carry 256 bits of randomness,
+// so a fast hash is enough and lets lookups use the unique index.
+func HashAPIKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey issues a key for ctx's tenant and returns it with its plaintext.
+// A nil expiresAt means the key doesn't expire.
+func CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time, createdBy int) (string, *APIKey, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return "", nil, database.ErrNoScope
+    }
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", nil, err
+    }
+    key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+    var k *APIKey
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        k, err = scanAPIKey(tx.QueryRow(ctx,
+            "INSERT INTO merchant_api_keys (tenant_id, name, prefix, key_hash, scopes, expires_at, created_by) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "+apiKeyColumns,
+            tenantID, name, key[:len(APIKeyPrefix)+6], HashAPIKey(key), scopes, expiresAt, createdBy,
+        ))
+        return err
+    })
+    if err != nil {
+        return "", nil, err
+    }
+    return key, k, nil
+}
+
+// GetAPIKey resolves a plaintext key to its record if it is neither revoked nor
+// expired. Callers don't know the tenant yet, so this needs a system scope.
+func GetAPIKey(ctx context.Context, key string) (*APIKey, error) {
+    var k *APIKey
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        k, err = scanAPIKey(tx.QueryRow(ctx,
+            "SELECT "+apiKeyColumns+" FROM merchant_api_keys WHERE key_hash=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())",
+            HashAPIKey(key)))
+        return err
+    })
+    return k, err
+}
+
+// TouchAPIKey records that a key was used. Writes are coalesced to one a minute
+// so busy integrations don't turn every request into an UPDATE.
+func TouchAPIKey(ctx context.Context, id int) error {
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        _, err := tx.Exec(ctx,
+            "UPDATE merchant_api_keys SET last_used_at=now() WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2) AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')",
+            id, database.TenantFilter(ctx))
+        return err
+    })
+}
+
+// ListAPIKeys lists ctx's tenant's keys, including revoked and expired ones, newest first
+func ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+    var res []*APIKey
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx,
+            "SELECT "+apiKeyColumns+" FROM merchant_api_keys WHERE ($1::int IS NULL OR tenant_id=$1) ORDER BY created_at DESC, id DESC",
+            database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            k, err := scanAPIKey(rows)
+            if err != nil {
+                return err
+            }
+            res = append(res, k)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
+// RevokeAPIKey revokes a key in ctx's tenant. It returns pgx.ErrNoRows if there is
+// no such key; revoking an already revoked key keeps the original revoked_at.
+func RevokeAPIKey(ctx context.Context, id int) (*APIKey, error) {
+    var k *APIKey
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        k, err = scanAPIKey(tx.QueryRow(ctx,
+            "UPDATE merchant_api_keys SET revoked_at=COALESCE(revoked_at, now()) WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2) RETURNING "+apiKeyColumns,
+            id, database.TenantFilter(ctx)))
+        return err
+    })
+    return k, err
+}
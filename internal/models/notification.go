@@ -0,0 +1,62 @@
+package models
+
+import (
+    "context"
+    "errors"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// NotificationPrefs are a user's choices for order notifications
+type NotificationPrefs struct {
+    UserID       int    `json:"-"`
+    EmailEnabled bool   `json:"email_enabled"`
+    SMSEnabled   bool   `json:"sms_enabled"`
+    Locale       string `json:"locale"`
+    // QuietStart and QuietEnd are HH:MM in Timezone; messages due in between are held
+    // until QuietEnd. Both empty means no quiet hours.
+    QuietStart string `json:"quiet_start"`
+    QuietEnd   string `json:"quiet_end"`
+    Timezone   string `json:"timezone"`
+}
+
+// DefaultNotificationPrefs applies to users who never saved preferences
+func DefaultNotificationPrefs(userID int) *NotificationPrefs {
+    return &NotificationPrefs{UserID: userID, EmailEnabled: true, Locale: "en", Timezone: "UTC"}
+}
+
+// GetNotificationPrefs returns the user's preferences, or the defaults if none are saved
+func GetNotificationPrefs(ctx context.Context, userID int) (*NotificationPrefs, error) {
+    p := &NotificationPrefs{UserID: userID}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx,
+            "SELECT email_enabled, sms_enabled, locale, COALESCE(quiet_start, ''), COALESCE(quiet_end, ''), timezone FROM notification_preferences WHERE user_id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx),
+        ).Scan(&p.EmailEnabled, &p.SMSEnabled, &p.Locale, &p.QuietStart, &p.QuietEnd, &p.Timezone)
+    })
+    if errors.Is(err, pgx.ErrNoRows) {
+        return DefaultNotificationPrefs(userID), nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// SaveNotificationPrefs creates or replaces the preferences of p.UserID in ctx's tenant
+func SaveNotificationPrefs(ctx context.Context, p *NotificationPrefs) error {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return database.ErrNoScope
+    }
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        _, err := tx.Exec(ctx, `INSERT INTO notification_preferences
+                (user_id, tenant_id, email_enabled, sms_enabled, locale, quiet_start, quiet_end, timezone)
+            VALUES ($1,$2,$3,$4,$5,NULLIF($6,''),NULLIF($7,''),$8)
+            ON CONFLICT (user_id) DO UPDATE SET email_enabled=$3, sms_enabled=$4, locale=$5,
+                quiet_start=NULLIF($6,''), quiet_end=NULLIF($7,''), timezone=$8, updated_at=now()`,
+            p.UserID, tenantID, p.EmailEnabled, p.SMSEnabled, p.Locale, p.QuietStart, p.QuietEnd, p.Timezone)
+        return err
+    })
+}
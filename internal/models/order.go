@@ -2,83 +2,449 @@ package models
 
 import (
     "context"
+    "errors"
+    "strconv"
+    "strings"
     "time"
 
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/cache"
     "github.com/rajnish-012/delivery-management-system/internal/database"
+    "github.com/rajnish-012/delivery-management-system/internal/geo"
 )
 
 type Order struct {
     ID         int       `json:"id"`
+    TenantID   int       `json:"tenant_id"`
     CustomerID int       `json:"customer_id"`
     Item       string    `json:"item"`
     Status     string    `json:"status"`
-    CreatedAt  time.Time `json:"created_at"`
-    UpdatedAt  time.Time `json:"updated_at"`
+    // Pickup and Dropoff are nil for orders placed without positions
+    Pickup  *geo.Point `json:"pickup"`
+    Dropoff *geo.Point `json:"dropoff"`
+    // ZoneID is the service zone the order was accepted in
+    ZoneID *int `json:"zone_id"`
+    // DeliverAfter and DeliverBefore bound when the drop-off should happen, if set
+    DeliverAfter  *time.Time `json:"deliver_after"`
+    DeliverBefore *time.Time `json:"deliver_before"`
+    // CourierID is the courier the order is assigned to
+    CourierID *int `json:"courier_id"`
+    // DispatchedAt, InTransitAt and DeliveredAt are when the order last reached
+    // each status
+    DispatchedAt *time.Time `json:"dispatched_at"`
+    InTransitAt  *time.Time `json:"in_transit_at"`
+    DeliveredAt  *time.Time `json:"delivered_at"`
+    // ETA is when the order is expected to be delivered, as of its last estimate
+    // (see internal/eta), and ETAConfidence how far to trust it, from 0 to 1. ETA
+    // is nil when there is no estimate.
+    ETA           *time.Time `json:"eta"`
+    ETAConfidence float64    `json:"eta_confidence"`
+    CreatedAt     time.Time  `json:"created_at"`
+    UpdatedAt     time.Time  `json:"updated_at"`
 }
 
-func CreateOrder(ctx context.Context, customerID int, item string) (*Order, error) {
-    var id int
-    err := database.Pool.QueryRow(ctx,
-        "INSERT INTO orders (customer_id, item, status) VALUES ($1,$2,$3) RETURNING id",
-        customerID, item, "created",
-    ).Scan(&id)
-    if err != nil {
-        return nil, err
-    }
-    return GetOrderByID(ctx, id)
-}
+const orderColumns = "id, tenant_id, customer_id, item, status, pickup_lat, pickup_lng, dropoff_lat, dropoff_lng, zone_id, deliver_after, deliver_before, courier_id, dispatched_at, in_transit_at, delivered_at, eta, eta_confidence, created_at, updated_at"
 
-func GetOrderByID(ctx context.Context, id int) (*Order, error) {
+func scanOrder(row pgx.Row) (*Order, error) {
     o := &Order{}
-    row := database.Pool.QueryRow(ctx, "SELECT id, customer_id, item, status, created_at, updated_at FROM orders WHERE id=$1", id)
-    if err := row.Scan(&o.ID, &o.CustomerID, &o.Item, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+    var pickupLat, pickupLng, dropoffLat, dropoffLng *float64
+    if err := row.Scan(&o.ID, &o.TenantID, &o.CustomerID, &o.Item, &o.Status,
+        &pickupLat, &pickupLng, &dropoffLat, &dropoffLng, &o.ZoneID, &o.DeliverAfter, &o.DeliverBefore, &o.CourierID,
+        &o.DispatchedAt, &o.InTransitAt, &o.DeliveredAt, &o.ETA, &o.ETAConfidence, &o.CreatedAt, &o.UpdatedAt); err != nil {
         return nil, err
     }
+    o.Pickup = point(pickupLat, pickupLng)
+    o.Dropoff = point(dropoffLat, dropoffLng)
     return o, nil
 }
 
-func UpdateOrderStatus(ctx context.Context, id int, status string) error {
-    _, err := database.Pool.Exec(ctx, "UPDATE orders SET status=$1, updated_at=now() WHERE id=$2", status, id)
-    return err
+func point(lat, lng *float64) *geo.Point {
+    if lat == nil || lng == nil {
+        return nil
+    }
+    return &geo.Point{Lat: *lat, Lng: *lng}
+}
+
+// coords splits p into nullable latitude and longitude columns
+func coords(p *geo.Point) (lat, lng *float64) {
+    if p == nil {
+        return nil, nil
+    }
+    return &p.Lat, &p.Lng
 }
 
-func CancelOrder(ctx context.Context, id int) error {
-    // only set cancelled if not delivered
-    _, err := database.Pool.Exec(ctx, "UPDATE orders SET status='cancelled', updated_at=now() WHERE id=$1 AND status != 'delivered'", id)
-    return err
+func queryOrders(ctx context.Context, sql string, args ...interface{}) ([]*Order, error) {
+    var res []*Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        res, err = queryOrdersTx(ctx, tx, sql, args...)
+        return err
+    })
+    return res, err
 }
 
-// List orders (admin/all or by customer)
-func ListOrdersByCustomer(ctx context.Context, customerID int) ([]*Order, error) {
-    rows, err := database.Pool.Query(ctx, "SELECT id, customer_id, item, status, created_at, updated_at FROM orders WHERE customer_id=$1 ORDER BY created_at DESC", customerID)
+func queryOrdersTx(ctx context.Context, tx pgx.Tx, sql string, args ...interface{}) ([]*Order, error) {
+    rows, err := tx.Query(ctx, sql, args...)
     if err != nil {
         return nil, err
     }
     defer rows.Close()
     var res []*Order
     for rows.Next() {
-        o := &Order{}
-        if err := rows.Scan(&o.ID, &o.CustomerID, &o.Item, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+        o, err := scanOrder(rows)
+        if err != nil {
             return nil, err
         }
         res = append(res, o)
     }
-    return res, nil
+    return res, rows.Err()
+}
+
+// cache keys include the tenant so a cached row can never answer another tenant's lookup
+func orderKey(tenantID, id int) string {
+    return "cache:order:" + strconv.Itoa(tenantID) + ":" + strconv.Itoa(id)
+}
+
+func customerOrdersKey(tenantID, customerID int) string {
+    return "cache:orders:customer:" + strconv.Itoa(tenantID) + ":" + strconv.Itoa(customerID)
+}
+
+// invalidateOrder drops the cached copies of an order after it changed
+func invalidateOrder(ctx context.Context, tenantID, id, customerID int) {
+    cache.Invalidate(ctx, orderKey(tenantID, id), customerOrdersKey(tenantID, customerID))
+}
+
+// insertOrder is the INSERT for a NewOrder; see insertArgs
+const insertOrder = "INSERT INTO orders (tenant_id, customer_id, item, status, pickup_lat, pickup_lng, dropoff_lat, dropoff_lng, zone_id, deliver_after, deliver_before) VALUES ($1,$2,$3,'created',$4,$5,$6,$7,$8,$9,$10) RETURNING " + orderColumns
+
+func (n NewOrder) insertArgs(tenantID int) []interface{} {
+    pickupLat, pickupLng := coords(n.Pickup)
+    dropoffLat, dropoffLng := coords(n.Dropoff)
+    return []interface{}{tenantID, n.CustomerID, n.Item, pickupLat, pickupLng, dropoffLat, dropoffLng, n.ZoneID, n.DeliverAfter, n.DeliverBefore}
+}
+
+// CreateOrder creates an order in ctx's tenant
+func CreateOrder(ctx context.Context, n NewOrder) (*Order, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return nil, database.ErrNoScope
+    }
+    var o *Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        o, err = scanOrder(tx.QueryRow(ctx, insertOrder, n.insertArgs(tenantID)...))
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    cache.Invalidate(ctx, customerOrdersKey(tenantID, n.CustomerID))
+    return o, nil
+}
+
+// GetOrderByID reads through the order cache. System-scoped lookups skip the cache.
+func GetOrderByID(ctx context.Context, id int) (*Order, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return getOrderByID(ctx, id)
+    }
+    return cache.Fetch(ctx, "order", orderKey(tenantID, id), func(ctx context.Context) (*Order, error) {
+        return getOrderByID(ctx, id)
+    })
+}
+
+// GetOrderUncached reads an order straight from Postgres. Use it where the status
+// read feeds a compare-and-set, which a cached copy even a moment old would lose.
+func GetOrderUncached(ctx context.Context, id int) (*Order, error) {
+    return getOrderByID(ctx, id)
+}
+
+func getOrderByID(ctx context.Context, id int) (*Order, error) {
+    var o *Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        o, err = scanOrder(tx.QueryRow(ctx,
+            "SELECT "+orderColumns+" FROM orders WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            id, database.TenantFilter(ctx)))
+        return err
+    })
+    return o, err
+}
+
+// stampStatus records when an order reached the status in $1, for status UPDATEs
+const stampStatus = `dispatched_at=CASE WHEN $1='dispatched' THEN now() ELSE dispatched_at END,
+    in_transit_at=CASE WHEN $1='in_transit' THEN now() ELSE in_transit_at END,
+    delivered_at=CASE WHEN $1='delivered' THEN now() ELSE delivered_at END`
+
+// UpdateOrderStatus moves an order from status from to status to, compare-and-set:
+// nothing is written unless the order is still in from. fence is the caller's
+// progression lease token (see internal/lock); the write is also refused if the order
+// was written under a newer lease. Callers not holding a lease pass 0.
+// It returns the updated order, or nil if the write was refused (the CAS was lost).
+func UpdateOrderStatus(ctx context.Context, id int, from, to string, fence int64) (*Order, error) {
+    return updateOrder(ctx, "UPDATE orders SET status=$1, "+stampStatus+", fence=GREATEST(fence, $4::bigint), updated_at=now() WHERE id=$2 AND status=$5 AND ($3::int IS NULL OR tenant_id=$3) AND ($4 = 0 OR fence <= $4) RETURNING "+orderColumns,
+        to, id, database.TenantFilter(ctx), fence, from)
+}
+
+// cancelAttempts bounds how often CancelOrder retries after losing a race with a
+// concurrent status change
+const cancelAttempts = 5
+
+// CancelOrder cancels an order that is not yet delivered, compare-and-set against
+// the status it last read. It returns the order as it is afterwards and, if this call
+// cancelled it, the status it was cancelled from; from is empty when the order was
+// already delivered or cancelled.
+func CancelOrder(ctx context.Context, id int) (o *Order, from string, err error) {
+    for i := 0; i < cancelAttempts; i++ {
+        // read past the cache: a stale status would only lose the CAS
+        if o, err = getOrderByID(ctx, id); err != nil {
+            return nil, "", err
+        }
+        if o.Status == "delivered" || o.Status == "cancelled" {
+            return o, "", nil
+        }
+        cancelled, err := UpdateOrderStatus(ctx, id, o.Status, "cancelled", 0)
+        if err != nil {
+            return nil, "", err
+        }
+        if cancelled != nil {
+            return cancelled, o.Status, nil
+        }
+    }
+    return o, "", nil
+}
+
+// StatusChange is an audit record of a manual status change made by an admin
+type StatusChange struct {
+    ID        int       `json:"id"`
+    OrderID   int       `json:"order_id"`
+    From      string    `json:"from"`
+    To        string    `json:"to"`
+    Reason    string    `json:"reason"`
+    Override  bool      `json:"override"`
+    ChangedBy int       `json:"changed_by"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// ChangeOrderStatus applies a manual status change, compare-and-set from c.From like
+// UpdateOrderStatus, and records c in the same transaction. It fills in c's ID and
+// CreatedAt and returns the updated order, or nil if the order is no longer in c.From.
+func ChangeOrderStatus(ctx context.Context, c *StatusChange) (*Order, error) {
+    var o *Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        o, err = scanOrder(tx.QueryRow(ctx,
+            "UPDATE orders SET status=$1, "+stampStatus+", updated_at=now() WHERE id=$2 AND status=$3 AND ($4::int IS NULL OR tenant_id=$4) RETURNING "+orderColumns,
+            c.To, c.OrderID, c.From, database.TenantFilter(ctx)))
+        if err != nil {
+            return err
+        }
+        return tx.QueryRow(ctx,
+            "INSERT INTO order_status_changes (tenant_id, order_id, from_status, to_status, reason, override, changed_by) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id, created_at",
+            o.TenantID, c.OrderID, c.From, c.To, c.Reason, c.Override, c.ChangedBy,
+        ).Scan(&c.ID, &c.CreatedAt)
+    })
+    if errors.Is(err, pgx.ErrNoRows) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    invalidateOrder(ctx, o.TenantID, o.ID, o.CustomerID)
+    return o, nil
+}
+
+// ListStatusChangesByCustomer returns the manual status changes made to a
+// customer's orders, oldest first
+func ListStatusChangesByCustomer(ctx context.Context, customerID int) ([]*StatusChange, error) {
+    var res []*StatusChange
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, `SELECT c.id, c.order_id, c.from_status, c.to_status, c.reason, c.override, c.changed_by, c.created_at
+            FROM order_status_changes c JOIN orders o ON o.id=c.order_id
+            WHERE o.customer_id=$1 AND ($2::int IS NULL OR c.tenant_id=$2) ORDER BY c.created_at, c.id`,
+            customerID, database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            c := &StatusChange{}
+            if err := rows.Scan(&c.ID, &c.OrderID, &c.From, &c.To, &c.Reason, &c.Override, &c.ChangedBy, &c.CreatedAt); err != nil {
+                return err
+            }
+            res = append(res, c)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
+// SetOrderETA stores a new estimate for an order in ctx's tenant and returns the
+// order, or nil if it doesn't exist
+func SetOrderETA(ctx context.Context, id int, eta *time.Time, confidence float64) (*Order, error) {
+    return updateOrder(ctx, "UPDATE orders SET eta=$1, eta_confidence=$2 WHERE id=$3 AND ($4::int IS NULL OR tenant_id=$4) RETURNING "+orderColumns,
+        eta, confidence, id, database.TenantFilter(ctx))
 }
 
+// updateOrder runs an UPDATE ... RETURNING orderColumns and invalidates the cached
+// copies of the order if a row changed. No matching row is not an error: the
+// returned order is nil.
+func updateOrder(ctx context.Context, sql string, args ...interface{}) (*Order, error) {
+    var o *Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        o, err = scanOrder(tx.QueryRow(ctx, sql, args...))
+        return err
+    })
+    if errors.Is(err, pgx.ErrNoRows) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    invalidateOrder(ctx, o.TenantID, o.ID, o.CustomerID)
+    return o, nil
+}
+
+// List orders (admin/all or by customer). Customer lists read through the cache.
+func ListOrdersByCustomer(ctx context.Context, customerID int) ([]*Order, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return listOrdersByCustomer(ctx, customerID)
+    }
+    return cache.Fetch(ctx, "customer_orders", customerOrdersKey(tenantID, customerID), func(ctx context.Context) ([]*Order, error) {
+        return listOrdersByCustomer(ctx, customerID)
+    })
+}
+
+func listOrdersByCustomer(ctx context.Context, customerID int) ([]*Order, error) {
+    return queryOrders(ctx, "SELECT "+orderColumns+" FROM orders WHERE customer_id=$1 AND ($2::int IS NULL OR tenant_id=$2) ORDER BY created_at DESC",
+        customerID, database.TenantFilter(ctx))
+}
+
+// ListAllOrders lists every order in ctx's tenant
 func ListAllOrders(ctx context.Context) ([]*Order, error) {
-    rows, err := database.Pool.Query(ctx, "SELECT id, customer_id, item, status, created_at, updated_at FROM orders ORDER BY created_at DESC")
+    return ListOrders(ctx, OrderFilter{})
+}
+
+// OrderFilter narrows admin order listings, exports and reports. Zero fields don't filter.
+type OrderFilter struct {
+    Status     string
+    CustomerID int
+    ZoneID     int
+    // From and To bound created_at: From inclusive, To exclusive
+    From time.Time
+    To   time.Time
+}
+
+// where returns the WHERE clause (tenant scope included) and its arguments
+func (f OrderFilter) where(ctx context.Context) (string, []interface{}) {
+    args := []interface{}{database.TenantFilter(ctx)}
+    conds := []string{"($1::int IS NULL OR tenant_id=$1)"}
+    add := func(cond string, arg interface{}) {
+        args = append(args, arg)
+        conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+    }
+    if f.Status != "" {
+        add("status=?", f.Status)
+    }
+    if f.CustomerID != 0 {
+        add("customer_id=?", f.CustomerID)
+    }
+    if f.ZoneID != 0 {
+        add("zone_id=?", f.ZoneID)
+    }
+    if !f.From.IsZero() {
+        add("created_at >= ?", f.From)
+    }
+    if !f.To.IsZero() {
+        add("created_at < ?", f.To)
+    }
+    return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ListOrders lists the orders in ctx's tenant matching f, newest first
+func ListOrders(ctx context.Context, f OrderFilter) ([]*Order, error) {
+    where, args := f.where(ctx)
+    return queryOrders(ctx, "SELECT "+orderColumns+" FROM orders"+where+" ORDER BY created_at DESC", args...)
+}
+
+// EachOrder streams the orders matching f, newest first, to fn without loading them
+// all into memory. Iteration stops at the first error from fn.
+func EachOrder(ctx context.Context, f OrderFilter, fn func(*Order) error) error {
+    where, args := f.where(ctx)
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, "SELECT "+orderColumns+" FROM orders"+where+" ORDER BY created_at DESC, id DESC", args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            o, err := scanOrder(rows)
+            if err != nil {
+                return err
+            }
+            if err := fn(o); err != nil {
+                return err
+            }
+        }
+        return rows.Err()
+    })
+}
+
+// ListActiveOrders returns orders that are neither delivered nor cancelled, oldest first
+func ListActiveOrders(ctx context.Context) ([]*Order, error) {
+    return queryOrders(ctx, "SELECT "+orderColumns+" FROM orders WHERE status NOT IN ('delivered','cancelled') AND ($1::int IS NULL OR tenant_id=$1) ORDER BY created_at",
+        database.TenantFilter(ctx))
+}
+
+// NewOrder is an order to create with CreateOrder or CreateOrders
+type NewOrder struct {
+    CustomerID int
+    Item       string
+    Pickup     *geo.Point
+    Dropoff    *geo.Point
+    ZoneID     *int
+    DeliverAfter  *time.Time
+    DeliverBefore *time.Time
+}
+
+// CreateOrders creates orders in ctx's tenant in one transaction, pipelined as a
+// single batch. COPY FROM would be faster but Postgres refuses it on tables with
+// row-level security. Results are in input order; on error nothing is created.
+func CreateOrders(ctx context.Context, list []NewOrder) ([]*Order, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return nil, database.ErrNoScope
+    }
+    res := make([]*Order, 0, len(list))
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        b := &pgx.Batch{}
+        for _, n := range list {
+            b.Queue(insertOrder, n.insertArgs(tenantID)...)
+        }
+        br := tx.SendBatch(ctx, b)
+        for range list {
+            o, err := scanOrder(br.QueryRow())
+            if err != nil {
+                br.Close()
+                return err
+            }
+            res = append(res, o)
+        }
+        return br.Close()
+    })
     if err != nil {
         return nil, err
     }
-    defer rows.Close()
-    var res []*Order
-    for rows.Next() {
-        o := &Order{}
-        if err := rows.Scan(&o.ID, &o.CustomerID, &o.Item, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
-            return nil, err
+    keys := make([]string, 0, len(res))
+    seen := make(map[int]bool)
+    for _, o := range res {
+        if !seen[o.CustomerID] {
+            seen[o.CustomerID] = true
+            keys = append(keys, customerOrdersKey(tenantID, o.CustomerID))
         }
-        res = append(res, o)
     }
+    cache.Invalidate(ctx, keys...)
     return res, nil
 }
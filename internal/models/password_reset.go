@@ -0,0 +1,79 @@
+package models
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidResetToken means a reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken issues a single-use token for a user in ctx's tenant,
+// valid for ttl, and returns its plaintext. Only its hash is stored.
+func CreatePasswordResetToken(ctx context.Context, userID int, ttl time.Duration) (string, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return "", database.ErrNoScope
+    }
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    token := base64.RawURLEncoding.EncodeToString(b)
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        _, err := tx.Exec(ctx,
+            "INSERT INTO password_reset_tokens (tenant_id, user_id, token_hash, expires_at) VALUES ($1,$2,$3,$4)",
+            tenantID, userID, HashAPIKey(token), time.Now().Add(ttl))
+        return err
+    })
+    if err != nil {
+        return "", err
+    }
+    return token, nil
+}
+
+// ResetPassword uses a reset token to set its user's password and revokes the
+// user's sessions. The token and every other outstanding token of the user are
+// spent in the same transaction, so a token works at most once. An error dropping
+// the cached session is returned although the password changed. Callers don't
+// know the tenant yet, so this needs a system scope.
+func ResetPassword(ctx context.Context, token, password string) (*User, error) {
+    pwHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return nil, err
+    }
+    var u *User
+    err = database.Scoped(ctx, func(tx pgx.Tx) error {
+        var userID int
+        err := tx.QueryRow(ctx,
+            "UPDATE password_reset_tokens SET used_at=now() WHERE token_hash=$1 AND used_at IS NULL AND expires_at > now() RETURNING user_id",
+            HashAPIKey(token)).Scan(&userID)
+        if errors.Is(err, pgx.ErrNoRows) {
+            return ErrInvalidResetToken
+        }
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(ctx,
+            "UPDATE password_reset_tokens SET used_at=now() WHERE user_id=$1 AND used_at IS NULL", userID); err != nil {
+            return err
+        }
+        u, err = scanUser(tx.QueryRow(ctx,
+            "UPDATE users SET password_hash=$1, sessions_revoked_at=$2 WHERE id=$3 RETURNING "+userColumns, string(pwHash), revocationTime(), userID))
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    if err := invalidateSession(ctx, u.TenantID, u.ID); err != nil {
+        return nil, err
+    }
+    return u, nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "strconv"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// ErrActiveOrders means a user can't be deleted while orders they placed or are
+// assigned to as a courier are still neither delivered nor cancelled
+var ErrActiveOrders = errors.New("user has active orders")
+
+// DeletedUsername is what DeleteUser renames a user to. Registration rejects ':',
+// so the name can't be taken by a new account.
+func DeletedUsername(id int) string {
+    return "deleted:" + strconv.Itoa(id)
+}
+
+// DeleteUser deletes a user in ctx's tenant by anonymizing them: the row stays so
+// their orders still reference a customer for accounting, but the name, contact
+// details, password and second factor are wiped and their preferences, recovery
+// codes, reset tokens and courier position and route are dropped. Their orders
+// keep item, status and zone but lose their pickup and drop-off positions.
+// Deleting a deleted user changes nothing but drops their cached session again. It returns the user as they were before, so callers can clean up data
+// held outside Postgres, or ErrActiveOrders.
+func DeleteUser(ctx context.Context, id int) (*User, error) {
+    var before *User
+    var orderIDs []int
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        // the row lock makes concurrent deletions of the same user queue up
+        before, err = scanUser(tx.QueryRow(ctx,
+            "SELECT "+userColumns+" FROM users WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2) FOR UPDATE",
+            id, database.TenantFilter(ctx)))
+        if err != nil || before.DeletedAt != nil {
+            return err
+        }
+        var active bool
+        if err := tx.QueryRow(ctx,
+            "SELECT EXISTS (SELECT 1 FROM orders WHERE (customer_id=$1 OR courier_id=$1) AND status NOT IN ('delivered','cancelled'))",
+            id).Scan(&active); err != nil {
+            return err
+        }
+        if active {
+            return ErrActiveOrders
+        }
+        if _, err := tx.Exec(ctx, `UPDATE users SET username=$1, password_hash='!', display_name=NULL, email=NULL, phone=NULL,
+                totp_secret=NULL, totp_enabled=false, disabled_at=COALESCE(disabled_at, now()), deleted_at=now()
+            WHERE id=$2`, DeletedUsername(id), id); err != nil {
+            return err
+        }
+        for _, table := range []string{"notification_preferences", "recovery_codes", "password_reset_tokens", "couriers"} {
+            if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE user_id=$1", id); err != nil {
+                return err
+            }
+        }
+        orderIDs, err = clearPositions(ctx, tx, "customer_id=$1", id)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    for _, orderID := range orderIDs {
+        invalidateOrder(ctx, before.TenantID, orderID, id)
+    }
+    if err := invalidateSession(ctx, before.TenantID, id); err != nil {
+        return nil, err
+    }
+    return before, nil
+}
+
+// clearPositions removes the pickup and drop-off positions of the orders matching
+// where and returns their ids
+func clearPositions(ctx context.Context, tx pgx.Tx, where string, args ...interface{}) ([]int, error) {
+    rows, err := tx.Query(ctx, `UPDATE orders SET pickup_lat=NULL, pickup_lng=NULL, dropoff_lat=NULL, dropoff_lng=NULL
+        WHERE (pickup_lat IS NOT NULL OR dropoff_lat IS NOT NULL) AND `+where+` RETURNING id`, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var ids []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+    }
+    return ids, rows.Err()
+}
+
+// PurgeOrderPositions removes the pickup and drop-off positions of up to limit
+// delivered or cancelled orders last updated before before. It returns how many
+// orders it changed.
+func PurgeOrderPositions(ctx context.Context, before time.Time, limit int) (int, error) {
+    var purged []*Order
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        purged, err = queryOrdersTx(ctx, tx, `UPDATE orders SET pickup_lat=NULL, pickup_lng=NULL, dropoff_lat=NULL, dropoff_lng=NULL
+            WHERE id IN (SELECT id FROM orders WHERE status IN ('delivered','cancelled') AND updated_at < $1
+                AND (pickup_lat IS NOT NULL OR dropoff_lat IS NOT NULL) AND ($2::int IS NULL OR tenant_id=$2) LIMIT $3)
+            RETURNING `+orderColumns, before, database.TenantFilter(ctx), limit)
+        return err
+    })
+    if err != nil {
+        return 0, err
+    }
+    for _, o := range purged {
+        invalidateOrder(ctx, o.TenantID, o.ID, o.CustomerID)
+    }
+    return len(purged), nil
+}
+
+// InactiveCustomers returns up to limit customers who haven't logged in since
+// before, counting accounts that never logged in from their creation, and who have
+// no active orders. Deleted users are left out.
+func InactiveCustomers(ctx context.Context, before time.Time, limit int) ([]*User, error) {
+    var users []*User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, `SELECT `+userColumns+` FROM users u
+            WHERE role='customer' AND deleted_at IS NULL AND COALESCE(last_login_at, created_at) < $1
+                AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.customer_id=u.id AND o.status NOT IN ('delivered','cancelled'))
+                AND ($2::int IS NULL OR tenant_id=$2)
+            ORDER BY id LIMIT $3`,
+            before, database.TenantFilter(ctx), limit)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            u, err := scanUser(rows)
+            if err != nil {
+                return err
+            }
+            users = append(users, u)
+        }
+        return rows.Err()
+    })
+    return users, err
+}
+
+// PurgeSpentCredentials deletes password reset tokens that were used or expired,
+// and recovery codes that were used, before before. It returns how many rows went.
+func PurgeSpentCredentials(ctx context.Context, before time.Time) (int64, error) {
+    var n int64
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        tag, err := tx.Exec(ctx,
+            "DELETE FROM password_reset_tokens WHERE COALESCE(used_at, expires_at) < $1 AND ($2::int IS NULL OR tenant_id=$2)",
+            before, database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        n = tag.RowsAffected()
+        tag, err = tx.Exec(ctx,
+            "DELETE FROM recovery_codes WHERE used_at < $1 AND ($2::int IS NULL OR tenant_id=$2)",
+            before, database.TenantFilter(ctx))
+        n += tag.RowsAffected()
+        return err
+    })
+    return n, err
+}
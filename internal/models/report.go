@@ -0,0 +1,93 @@
+package models
+
+import (
+    "context"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// StatusCount is the number of orders created on Day that are now in Status
+type StatusCount struct {
+    Day    string `json:"day"` // YYYY-MM-DD, UTC
+    Status string `json:"status"`
+    Count  int    `json:"count"`
+}
+
+// DeliveryTime summarises how long delivered orders took
+type DeliveryTime struct {
+    Delivered  int     `json:"delivered"`
+    AvgSeconds float64 `json:"avg_seconds"`
+}
+
+// CustomerCancellations is one customer's cancellation rate
+type CustomerCancellations struct {
+    CustomerID int     `json:"customer_id"`
+    Orders     int     `json:"orders"`
+    Cancelled  int     `json:"cancelled"`
+    Rate       float64 `json:"rate"`
+}
+
+// OrdersPerStatusPerDay counts orders matching f by creation day and current status
+func OrdersPerStatusPerDay(ctx context.Context, f OrderFilter) ([]StatusCount, error) {
+    where, args := f.where(ctx)
+    res := []StatusCount{}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, `SELECT to_char(date_trunc('day', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD'), status, count(*)
+            FROM orders`+where+` GROUP BY 1, 2 ORDER BY 1, 2`, args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var c StatusCount
+            if err := rows.Scan(&c.Day, &c.Status, &c.Count); err != nil {
+                return err
+            }
+            res = append(res, c)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
+// AverageDeliveryTime averages created-to-delivered time over delivered orders matching f
+func AverageDeliveryTime(ctx context.Context, f OrderFilter) (*DeliveryTime, error) {
+    f.Status = "delivered"
+    where, args := f.where(ctx)
+    d := &DeliveryTime{}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx, `SELECT count(*), COALESCE(avg(extract(epoch FROM delivered_at - created_at)), 0)::float8
+            FROM orders`+where, args...).Scan(&d.Delivered, &d.AvgSeconds)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return d, nil
+}
+
+// CancellationRates returns each customer's share of cancelled orders among those
+// matching f, highest rate first
+func CancellationRates(ctx context.Context, f OrderFilter) ([]CustomerCancellations, error) {
+    where, args := f.where(ctx)
+    res := []CustomerCancellations{}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, `SELECT customer_id, count(*), count(*) FILTER (WHERE status = 'cancelled'),
+                (count(*) FILTER (WHERE status = 'cancelled'))::float8 / count(*)
+            FROM orders`+where+` GROUP BY customer_id ORDER BY 4 DESC, 1`, args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var c CustomerCancellations
+            if err := rows.Scan(&c.CustomerID, &c.Orders, &c.Cancelled, &c.Rate); err != nil {
+                return err
+            }
+            res = append(res, c)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
@@ -0,0 +1,72 @@
+package models
+
+import (
+    "context"
+    "strconv"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/cache"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// Session is what a user's tokens are checked against on every request. Postgres
+// holds it; lookups read through the cache, and every change invalidates it.
+type Session struct {
+    // Disabled is set for disabled and deleted accounts
+    Disabled bool `json:"disabled"`
+    // RevokedAt voids every token issued before it
+    RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func sessionKey(tenantID, userID int) string {
+    return "cache:session:" + strconv.Itoa(tenantID) + ":" + strconv.Itoa(userID)
+}
+
+// invalidateSession drops the cached session of a user after it changed. Unlike a
+// stale order, a stale session would keep a disabled user's tokens working, so the
+// error is returned and the change reported as failed; retrying it invalidates again.
+func invalidateSession(ctx context.Context, tenantID, userID int) error {
+    return cache.Invalidate(ctx, sessionKey(tenantID, userID))
+}
+
+// GetSession returns the session of a user in ctx's tenant, or pgx.ErrNoRows if
+// there is no such user
+func GetSession(ctx context.Context, userID int) (*Session, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return nil, database.ErrNoScope
+    }
+    return cache.Fetch(ctx, "session", sessionKey(tenantID, userID), func(ctx context.Context) (*Session, error) {
+        s := &Session{}
+        err := database.Scoped(ctx, func(tx pgx.Tx) error {
+            return tx.QueryRow(ctx,
+                "SELECT disabled_at IS NOT NULL OR deleted_at IS NOT NULL, sessions_revoked_at FROM users WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+                userID, database.TenantFilter(ctx)).Scan(&s.Disabled, &s.RevokedAt)
+        })
+        return s, err
+    })
+}
+
+// revocationTime is what sessions_revoked_at is set to. Tokens carry their issue
+// time from the app's clock, so revocations are stamped from it too rather than by
+// Postgres, whose clock may run ahead and void the token issued right after.
+func revocationTime() time.Time {
+    return time.Now()
+}
+
+// RevokeSessions voids every token issued to a user in ctx's tenant up to now. It
+// returns pgx.ErrNoRows if there is no such user, or an error if the cached session
+// couldn't be dropped.
+func RevokeSessions(ctx context.Context, userID int) error {
+    var tenantID int
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx,
+            "UPDATE users SET sessions_revoked_at=$1 WHERE id=$2 AND ($3::int IS NULL OR tenant_id=$3) RETURNING tenant_id",
+            revocationTime(), userID, database.TenantFilter(ctx)).Scan(&tenantID)
+    })
+    if err != nil {
+        return err
+    }
+    return invalidateSession(ctx, tenantID, userID)
+}
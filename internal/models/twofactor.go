@@ -0,0 +1,142 @@
+package models
+
+import (
+    "context"
+    "errors"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// ErrTOTPEnabled means a user already has two-factor authentication switched on
+var ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+// TOTP is a user's two-factor state. Secret is the base32 key, set at enrollment;
+// it only counts once Enabled is true.
+type TOTP struct {
+    Secret   string
+    Enabled  bool
+    LastStep int64
+}
+
+// GetTOTP returns a user's two-factor state
+func GetTOTP(ctx context.Context, userID int) (*TOTP, error) {
+    t := &TOTP{}
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx,
+            "SELECT COALESCE(totp_secret, ''), totp_enabled, totp_last_step FROM users WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx)).Scan(&t.Secret, &t.Enabled, &t.LastStep)
+    })
+    return t, err
+}
+
+// SetPendingTOTP stores a new secret for a user who hasn't enabled two-factor yet,
+// replacing any earlier unconfirmed one. It returns ErrTOTPEnabled otherwise.
+func SetPendingTOTP(ctx context.Context, userID int, secret string) error {
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        tag, err := tx.Exec(ctx,
+            "UPDATE users SET totp_secret=$1 WHERE id=$2 AND NOT totp_enabled AND ($3::int IS NULL OR tenant_id=$3)",
+            secret, userID, database.TenantFilter(ctx))
+        if err == nil && tag.RowsAffected() == 0 {
+            return ErrTOTPEnabled
+        }
+        return err
+    })
+}
+
+// EnableTOTP switches two-factor on with the pending secret, consuming step, and
+// replaces the user's recovery codes with codeHashes. It reports false if
+// two-factor was already on or step was already used.
+func EnableTOTP(ctx context.Context, userID int, step int64, codeHashes []string) (bool, error) {
+    ok := false
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var tenantID int
+        err := tx.QueryRow(ctx,
+            "UPDATE users SET totp_enabled=true, totp_last_step=$1 WHERE id=$2 AND NOT totp_enabled AND totp_secret IS NOT NULL AND totp_last_step < $1 AND ($3::int IS NULL OR tenant_id=$3) RETURNING tenant_id",
+            step, userID, database.TenantFilter(ctx)).Scan(&tenantID)
+        if errors.Is(err, pgx.ErrNoRows) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        ok = true
+        return replaceRecoveryCodes(ctx, tx, tenantID, userID, codeHashes)
+    })
+    return ok, err
+}
+
+// DisableTOTP switches two-factor off and drops the secret and recovery codes
+func DisableTOTP(ctx context.Context, userID int) error {
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        if _, err := tx.Exec(ctx,
+            "UPDATE users SET totp_secret=NULL, totp_enabled=false WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx)); err != nil {
+            return err
+        }
+        _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx))
+        return err
+    })
+}
+
+// UseTOTPStep records that a code for step was accepted. It reports false if that
+// step or a later one was already used, which makes each code single-use.
+func UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+    ok := false
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        tag, err := tx.Exec(ctx,
+            "UPDATE users SET totp_last_step=$1 WHERE id=$2 AND totp_enabled AND totp_last_step < $1 AND ($3::int IS NULL OR tenant_id=$3)",
+            step, userID, database.TenantFilter(ctx))
+        ok = tag.RowsAffected() == 1
+        return err
+    })
+    return ok, err
+}
+
+// ReplaceRecoveryCodes drops a user's recovery codes and stores codeHashes instead
+func ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        var tenantID int
+        if err := tx.QueryRow(ctx, "SELECT tenant_id FROM users WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx)).Scan(&tenantID); err != nil {
+            return err
+        }
+        return replaceRecoveryCodes(ctx, tx, tenantID, userID, codeHashes)
+    })
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, tenantID, userID int, codeHashes []string) error {
+    if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id=$1", userID); err != nil {
+        return err
+    }
+    _, err := tx.Exec(ctx,
+        "INSERT INTO recovery_codes (tenant_id, user_id, code_hash) SELECT $1, $2, unnest($3::text[])",
+        tenantID, userID, codeHashes)
+    return err
+}
+
+// UseRecoveryCode spends one of a user's recovery codes. It reports false if the
+// code is unknown or already used.
+func UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+    ok := false
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        tag, err := tx.Exec(ctx,
+            "UPDATE recovery_codes SET used_at=now() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL AND ($3::int IS NULL OR tenant_id=$3)",
+            userID, codeHash, database.TenantFilter(ctx))
+        ok = tag.RowsAffected() == 1
+        return err
+    })
+    return ok, err
+}
+
+// RecoveryCodesLeft counts a user's unused recovery codes
+func RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+    var n int
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx,
+            "SELECT count(*) FROM recovery_codes WHERE user_id=$1 AND used_at IS NULL AND ($2::int IS NULL OR tenant_id=$2)",
+            userID, database.TenantFilter(ctx)).Scan(&n)
+    })
+    return n, err
+}
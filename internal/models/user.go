@@ -1,58 +1,266 @@
-
 package models
 
 import (
     "context"
     "errors"
+    "strconv"
+    "strings"
+    "time"
+
     "golang.org/x/crypto/bcrypt"
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgconn"
     "github.com/rajnish-012/delivery-management-system/internal/database"
 )
 
 type User struct {
     ID           int
+    TenantID     int
     Username     string
     PasswordHash string
-    Role         string // "customer" or "admin"
+    Role         string // "customer", "admin" or "courier"
+    TOTPEnabled  bool
+    CreatedAt    time.Time
+    DisabledAt   *time.Time // nil while the account is active
+    DeletedAt    *time.Time // set once the account is deleted and anonymized
+    LastLoginAt  *time.Time
+    Profile
+}
+
+// Profile holds a user's optional display name and contact details; empty means not set
+type Profile struct {
+    DisplayName string
+    Email       string
+    Phone       string
 }
 
+const userColumns = "id, tenant_id, username, password_hash, role, totp_enabled, created_at, disabled_at, deleted_at, last_login_at, COALESCE(display_name, ''), COALESCE(email, ''), COALESCE(phone, '')"
+
 func (u *User) CheckPassword(password string) bool {
     err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
     return err == nil
 }
 
-func CreateUser(ctx context.Context, username, password, role string) (*User, error) {
-    if role != "customer" && role != "admin" {
+// ValidRole reports whether role is a role users can have
+func ValidRole(role string) bool {
+    return role == "customer" || role == "admin" || role == "courier"
+}
+
+func scanUser(row pgx.Row) (*User, error) {
+    u := &User{}
+    if err := row.Scan(&u.ID, &u.TenantID, &u.Username, &u.PasswordHash, &u.Role, &u.TOTPEnabled, &u.CreatedAt, &u.DisabledAt, &u.DeletedAt, &u.LastLoginAt, &u.DisplayName, &u.Email, &u.Phone); err != nil {
+        return nil, err
+    }
+    return u, nil
+}
+
+// CreateUser creates a user in ctx's tenant
+func CreateUser(ctx context.Context, username, password, role string, p Profile) (*User, error) {
+    if !ValidRole(role) {
         return nil, errors.New("invalid role")
     }
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return nil, database.ErrNoScope
+    }
     pwHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     if err != nil {
         return nil, err
     }
-    var id int
-    err = database.Pool.QueryRow(ctx,
-        "INSERT INTO users (username, password_hash, role) VALUES ($1,$2,$3) RETURNING id",
-        username, string(pwHash), role,
-    ).Scan(&id)
+    var u *User
+    err = database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        u, err = scanUser(tx.QueryRow(ctx,
+            "INSERT INTO users (tenant_id, username, password_hash, role, display_name, email, phone) VALUES ($1,$2,$3,$4,NULLIF($5,''),NULLIF($6,''),NULLIF($7,'')) RETURNING "+userColumns,
+            tenantID, username, string(pwHash), role, p.DisplayName, p.Email, p.Phone,
+        ))
+        return err
+    })
+    return u, err
+}
+
+// EnsureUser creates a user in ctx's tenant unless the username is taken, and
+// reports whether it did. The server uses it to create the operator's first admin.
+func EnsureUser(ctx context.Context, username, password, role string) (bool, error) {
+    _, err := CreateUser(ctx, username, password, role, Profile{})
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+// TouchLogin records that a user just logged in
+func TouchLogin(ctx context.Context, id int) error {
+    return database.Scoped(ctx, func(tx pgx.Tx) error {
+        _, err := tx.Exec(ctx, "UPDATE users SET last_login_at=now() WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            id, database.TenantFilter(ctx))
+        return err
+    })
+}
+
+// UpdateProfile replaces a user's profile and returns the updated user
+func UpdateProfile(ctx context.Context, id int, p Profile) (*User, error) {
+    var u *User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        u, err = scanUser(tx.QueryRow(ctx,
+            "UPDATE users SET display_name=NULLIF($1,''), email=NULLIF($2,''), phone=NULLIF($3,'') WHERE id=$4 AND ($5::int IS NULL OR tenant_id=$5) RETURNING "+userColumns,
+            p.DisplayName, p.Email, p.Phone, id, database.TenantFilter(ctx)))
+        return err
+    })
+    return u, err
+}
+
+// SetPassword replaces a user's password and, in the same update, revokes their
+// sessions (see RevokeSessions)
+func SetPassword(ctx context.Context, id int, password string) error {
+    pwHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     if err != nil {
-        return nil, err
+        return err
+    }
+    var tenantID int
+    err = database.Scoped(ctx, func(tx pgx.Tx) error {
+        return tx.QueryRow(ctx, "UPDATE users SET password_hash=$1, sessions_revoked_at=$2 WHERE id=$3 AND ($4::int IS NULL OR tenant_id=$4) RETURNING tenant_id",
+            string(pwHash), revocationTime(), id, database.TenantFilter(ctx)).Scan(&tenantID)
+    })
+    if err != nil {
+        return err
     }
-    return &User{ID: id, Username: username, PasswordHash: string(pwHash), Role: role}, nil
+    return invalidateSession(ctx, tenantID, id)
 }
 
+// GetUserByUsername looks a user up by name. Usernames are unique across tenants,
+// so login runs this under a system scope to learn the user's tenant.
 func GetUserByUsername(ctx context.Context, username string) (*User, error) {
-    u := &User{}
-    row := database.Pool.QueryRow(ctx, "SELECT id, username, password_hash, role FROM users WHERE username=$1", username)
-    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role); err != nil {
-        return nil, err
-    }
-    return u, nil
+    var u *User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        u, err = scanUser(tx.QueryRow(ctx,
+            "SELECT "+userColumns+" FROM users WHERE username=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            username, database.TenantFilter(ctx)))
+        return err
+    })
+    return u, err
 }
 
 func GetUserByID(ctx context.Context, id int) (*User, error) {
-    u := &User{}
-    row := database.Pool.QueryRow(ctx, "SELECT id, username, password_hash, role FROM users WHERE id=$1", id)
-    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role); err != nil {
+    var u *User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        u, err = scanUser(tx.QueryRow(ctx,
+            "SELECT "+userColumns+" FROM users WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            id, database.TenantFilter(ctx)))
+        return err
+    })
+    return u, err
+}
+
+// ExistingUserIDs reports which of ids are users in ctx's tenant. Deleted users
+// don't count.
+func ExistingUserIDs(ctx context.Context, ids []int) (map[int]bool, error) {
+    found := make(map[int]bool, len(ids))
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, "SELECT id FROM users WHERE id = ANY($1) AND deleted_at IS NULL AND ($2::int IS NULL OR tenant_id=$2)",
+            ids, database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var id int
+            if err := rows.Scan(&id); err != nil {
+                return err
+            }
+            found[id] = true
+        }
+        return rows.Err()
+    })
+    return found, err
+}
+
+// UserFilter narrows ListUsers. Results are ordered by id; After and Limit page
+// through them.
+type UserFilter struct {
+    // Query matches part of the username, display name or email, ignoring case
+    Query    string
+    Role     string
+    Disabled *bool
+    // After is the last id of the previous page
+    After int
+    Limit int
+}
+
+// ListUsers returns the users in ctx's tenant matching f
+func ListUsers(ctx context.Context, f UserFilter) ([]*User, error) {
+    args := []interface{}{database.TenantFilter(ctx), f.After, f.Limit}
+    conds := []string{"($1::int IS NULL OR tenant_id=$1)", "id > $2"}
+    add := func(cond string, arg interface{}) {
+        args = append(args, arg)
+        conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+    }
+    if f.Query != "" {
+        like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Query) + "%"
+        add("(username ILIKE ? OR display_name ILIKE ? OR email ILIKE ?)", like)
+    }
+    if f.Role != "" {
+        add("role=?", f.Role)
+    }
+    if f.Disabled != nil {
+        add("(disabled_at IS NOT NULL)=?", *f.Disabled)
+    }
+    var users []*User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx,
+            "SELECT "+userColumns+" FROM users WHERE "+strings.Join(conds, " AND ")+" ORDER BY id LIMIT $3", args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            u, err := scanUser(rows)
+            if err != nil {
+                return err
+            }
+            users = append(users, u)
+        }
+        return rows.Err()
+    })
+    return users, err
+}
+
+// SetUserRole changes a user's role and returns the updated user. Deleted users
+// are not found.
+func SetUserRole(ctx context.Context, id int, role string) (*User, error) {
+    if !ValidRole(role) {
+        return nil, errors.New("invalid role")
+    }
+    return updateUser(ctx, "UPDATE users SET role=$1 WHERE id=$2 AND deleted_at IS NULL AND ($3::int IS NULL OR tenant_id=$3) RETURNING "+userColumns,
+        role, id, database.TenantFilter(ctx))
+}
+
+// SetUserDisabled disables or re-enables a user and returns the updated user.
+// Disabling an already disabled user keeps the original time. Deleted users are
+// not found. If the cached session can't be dropped it returns an error although
+// the change was made; retrying drops it again.
+func SetUserDisabled(ctx context.Context, id int, disabled bool) (*User, error) {
+    u, err := updateUser(ctx, "UPDATE users SET disabled_at=CASE WHEN $1 THEN COALESCE(disabled_at, now()) END WHERE id=$2 AND deleted_at IS NULL AND ($3::int IS NULL OR tenant_id=$3) RETURNING "+userColumns,
+        disabled, id, database.TenantFilter(ctx))
+    if err != nil {
+        return nil, err
+    }
+    if err := invalidateSession(ctx, u.TenantID, u.ID); err != nil {
         return nil, err
     }
     return u, nil
 }
+
+func updateUser(ctx context.Context, sql string, args ...interface{}) (*User, error) {
+    var u *User
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        u, err = scanUser(tx.QueryRow(ctx, sql, args...))
+        return err
+    })
+    return u, err
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+    "context"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/rajnish-012/delivery-management-system/internal/database"
+    "github.com/rajnish-012/delivery-management-system/internal/geo"
+)
+
+// Zone is an area a merchant delivers in. Inactive zones are kept so orders
+// tagged with them still resolve, but accept no new orders.
+type Zone struct {
+    ID        int         `json:"id"`
+    TenantID  int         `json:"tenant_id"`
+    Name      string      `json:"name"`
+    Polygon   geo.Polygon `json:"polygon"`
+    Active    bool        `json:"active"`
+    CreatedAt time.Time   `json:"created_at"`
+    UpdatedAt time.Time   `json:"updated_at"`
+}
+
+const zoneColumns = "id, tenant_id, name, polygon, active, created_at, updated_at"
+
+func scanZone(row pgx.Row) (*Zone, error) {
+    z := &Zone{}
+    if err := row.Scan(&z.ID, &z.TenantID, &z.Name, &z.Polygon, &z.Active, &z.CreatedAt, &z.UpdatedAt); err != nil {
+        return nil, err
+    }
+    return z, nil
+}
+
+// CreateZone adds a zone to ctx's tenant. The polygon is stored as given; callers
+// validate it first.
+func CreateZone(ctx context.Context, name string, polygon geo.Polygon, active bool) (*Zone, error) {
+    tenantID, ok := database.TenantID(ctx)
+    if !ok {
+        return nil, database.ErrNoScope
+    }
+    var z *Zone
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        z, err = scanZone(tx.QueryRow(ctx,
+            "INSERT INTO service_zones (tenant_id, name, polygon, active) VALUES ($1,$2,$3,$4) RETURNING "+zoneColumns,
+            tenantID, name, polygon, active))
+        return err
+    })
+    return z, err
+}
+
+// UpdateZone replaces a zone's name, polygon and active flag and returns the
+// updated zone. Orders already tagged with the zone keep their tag.
+func UpdateZone(ctx context.Context, id int, name string, polygon geo.Polygon, active bool) (*Zone, error) {
+    var z *Zone
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        z, err = scanZone(tx.QueryRow(ctx,
+            "UPDATE service_zones SET name=$1, polygon=$2, active=$3, updated_at=now() WHERE id=$4 AND ($5::int IS NULL OR tenant_id=$5) RETURNING "+zoneColumns,
+            name, polygon, active, id, database.TenantFilter(ctx)))
+        return err
+    })
+    return z, err
+}
+
+func GetZone(ctx context.Context, id int) (*Zone, error) {
+    var z *Zone
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        var err error
+        z, err = scanZone(tx.QueryRow(ctx,
+            "SELECT "+zoneColumns+" FROM service_zones WHERE id=$1 AND ($2::int IS NULL OR tenant_id=$2)",
+            id, database.TenantFilter(ctx)))
+        return err
+    })
+    return z, err
+}
+
+// ListZones returns every zone in ctx's tenant, active or not, by id
+func ListZones(ctx context.Context) ([]*Zone, error) {
+    var res []*Zone
+    err := database.Scoped(ctx, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx,
+            "SELECT "+zoneColumns+" FROM service_zones WHERE ($1::int IS NULL OR tenant_id=$1) ORDER BY id",
+            database.TenantFilter(ctx))
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            z, err := scanZone(rows)
+            if err != nil {
+                return err
+            }
+            res = append(res, z)
+        }
+        return rows.Err()
+    })
+    return res, err
+}
+
+// ZoneAt returns the first active zone in zones that contains p, or nil. With
+// zones in id order, overlapping zones resolve to the oldest.
+func ZoneAt(zones []*Zone, p geo.Point) *Zone {
+    for _, z := range zones {
+        if z.Active && z.Polygon.Contains(p) {
+            return z
+        }
+    }
+    return nil
+}
@@ -0,0 +1,394 @@
+// Package notify tells customers about their orders. It consumes order status
+// changes, renders the per-status template in the customer's locale and sends it
+// over the channels the customer enabled, holding messages during their quiet hours.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+// Channels a message can be sent over
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// deferredKey is the Redis sorted set of messages held for quiet hours, scored by
+// the unix time they are due
+const deferredKey = "notify:deferred"
+
+// pollInterval is how often held messages are checked for being due
+const pollInterval = 30 * time.Second
+
+// Message is one rendered notification
+type Message struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+	OrderID int    `json:"order_id"`
+	Status  string `json:"status"`
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// event is an order status change waiting to be notified
+type event struct {
+	tenantID int
+	orderID  int
+	status   string
+}
+
+var (
+	mu      sync.RWMutex
+	sinks   = map[string]Notifier{}
+	locale  = "en"
+	queue   chan event
+	workers *background.Manager
+)
+
+// Configure builds the channel sinks from cfg and sets the background manager the
+// workers run under. Nothing is sent until Start.
+func Configure(cfg config.NotifyConfig, bg *background.Manager) {
+	s := map[string]Notifier{}
+	file := NewFileNotifier(cfg.File) // shared so both channels' lines don't interleave
+	switch cfg.Email {
+	case "smtp":
+		s[ChannelEmail] = NewSMTPNotifier(cfg.SMTP)
+	case "file":
+		s[ChannelEmail] = file
+	case "log":
+		s[ChannelEmail] = LogNotifier{}
+	}
+	switch cfg.SMS {
+	case "provider":
+		s[ChannelSMS] = NewSMSProviderNotifier(cfg.Provider)
+	case "file":
+		s[ChannelSMS] = file
+	case "log":
+		s[ChannelSMS] = LogNotifier{}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sinks = s
+	locale = cfg.DefaultLocale
+	queue = make(chan event, cfg.QueueSize)
+	workers = bg
+}
+
+// SetNotifier replaces the sink for channel, or removes it if n is nil.
+func SetNotifier(channel string, n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	if n == nil {
+		delete(sinks, channel)
+		return
+	}
+	sinks[channel] = n
+}
+
+func notifier(channel string) Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sinks[channel]
+}
+
+func defaultLocale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return locale
+}
+
+// Start runs the worker that sends notifications for queued status changes and
+// the poller that releases messages held for quiet hours. Call it once, after the
+// database and Redis are up.
+func Start() error {
+	mu.RLock()
+	q, bg := queue, workers
+	mu.RUnlock()
+	if err := bg.Go(func(ctx context.Context) {
+		for {
+			select {
+			case ev := <-q:
+				handle(ctx, ev)
+			case <-bg.Stopping():
+				if n := len(q); n > 0 {
+					logging.FromContext(ctx).Warn("notifications dropped at shutdown", "count", n)
+				}
+				return
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	return bg.Go(func(ctx context.Context) {
+		t := time.NewTicker(pollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				ReleaseDue(ctx, now)
+			case <-bg.Stopping():
+				return
+			}
+		}
+	})
+}
+
+// OrderStatusChanged queues notifications for an order that moved to status. It
+// never blocks: when the queue is full the change is dropped and counted. ctx's
+// tenant scope identifies the order.
+func OrderStatusChanged(ctx context.Context, orderID int, status string) {
+	mu.RLock()
+	q := queue
+	mu.RUnlock()
+	if q == nil {
+		return
+	}
+	tenantID, _ := database.TenantID(ctx)
+	select {
+	case q <- event{tenantID: tenantID, orderID: orderID, status: status}:
+	default:
+		metrics.ObserveNotification("any", "dropped")
+		logging.FromContext(ctx).Warn("notification queue full, dropping",
+			"order_id", orderID, "status", status)
+	}
+}
+
+// handle looks up the order's customer and their preferences, then sends or holds
+// a message on every channel they enabled and have an address for.
+func handle(ctx context.Context, ev event) {
+	if ev.tenantID != 0 {
+		ctx = database.WithTenant(ctx, ev.tenantID)
+	} else {
+		ctx = database.WithSystem(ctx)
+	}
+	logger := logging.FromContext(ctx).With("order_id", ev.orderID, "status", ev.status)
+	o, err := models.GetOrderByID(ctx, ev.orderID)
+	if err != nil {
+		logger.Error("notify: failed to load order", "error", err)
+		return
+	}
+	u, err := models.GetUserByID(ctx, o.CustomerID)
+	if err != nil {
+		logger.Error("notify: failed to load customer", "error", err)
+		return
+	}
+	prefs, err := models.GetNotificationPrefs(ctx, u.ID)
+	if err != nil {
+		logger.Error("notify: failed to load preferences", "error", err)
+		return
+	}
+	for _, m := range Compose(ctx, u, prefs, o, ev.status) {
+		Deliver(ctx, m, prefs, time.Now())
+	}
+}
+
+// Compose renders the messages for order o moving to status, one per channel the
+// user enabled and has an address for. Render errors are logged and skip the channel.
+func Compose(ctx context.Context, u *models.User, prefs *models.NotificationPrefs, o *models.Order, status string) []Message {
+	var res []Message
+	add := func(channel, to string) {
+		subject, body, ok, err := Render(prefs.Locale, status, channel, Data{Name: displayName(u), OrderID: o.ID, Item: o.Item})
+		if err != nil {
+			logging.FromContext(ctx).Error("notify: render failed", "order_id", o.ID, "channel", channel, "error", err)
+			return
+		}
+		if ok {
+			res = append(res, Message{Channel: channel, To: to, Subject: subject, Body: body, OrderID: o.ID, Status: status})
+		}
+	}
+	if prefs.EmailEnabled && u.Email != "" {
+		add(ChannelEmail, u.Email)
+	}
+	if prefs.SMSEnabled && u.Phone != "" {
+		add(ChannelSMS, u.Phone)
+	}
+	return res
+}
+
+// PasswordReset sends u a password reset link by email, or by SMS if they have no
+// email, rendered in locale. It is sent right away, ignoring quiet hours: the user
+// is waiting for it.
+func PasswordReset(ctx context.Context, u *models.User, locale, link string) error {
+	channel, to := ChannelEmail, u.Email
+	if to == "" {
+		channel, to = ChannelSMS, u.Phone
+	}
+	if to == "" {
+		return fmt.Errorf("user %d has no email or phone", u.ID)
+	}
+	n := notifier(channel)
+	if n == nil {
+		return fmt.Errorf("no %s sink configured", channel)
+	}
+	subject, body, _, err := Render(locale, PasswordResetTemplate, channel, Data{Name: displayName(u), Link: link})
+	if err != nil {
+		return err
+	}
+	m := Message{Channel: channel, To: to, Subject: subject, Body: body, Status: PasswordResetTemplate}
+	if err := n.Send(ctx, m); err != nil {
+		metrics.ObserveNotification(channel, "failed")
+		return err
+	}
+	metrics.ObserveNotification(channel, "sent")
+	return nil
+}
+
+func displayName(u *models.User) string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// Deliver sends m now, or holds it until prefs' quiet hours end. Without Redis to
+// hold it in, a message in quiet hours is sent anyway.
+func Deliver(ctx context.Context, m Message, prefs *models.NotificationPrefs, now time.Time) {
+	if until, quiet := QuietUntil(now, prefs); quiet && database.Rdb != nil {
+		b, err := json.Marshal(m)
+		if err == nil {
+			err = database.Rdb.ZAdd(ctx, deferredKey, &redis.Z{Score: float64(until.Unix()), Member: b}).Err()
+		}
+		if err == nil {
+			metrics.ObserveNotification(m.Channel, "deferred")
+			return
+		}
+		logging.FromContext(ctx).Error("notify: failed to hold message for quiet hours, sending now",
+			"order_id", m.OrderID, "error", err)
+	}
+	send(ctx, m)
+}
+
+// ReleaseDue sends the held messages that are due at now. Each message is removed
+// from Redis before it is sent, so with several replicas polling only one sends it.
+func ReleaseDue(ctx context.Context, now time.Time) {
+	if database.Rdb == nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	due, err := database.Rdb.ZRangeByScore(ctx, deferredKey, &redis.ZRangeBy{
+		Min: "-inf", Max: strconv.FormatInt(now.Unix(), 10), Count: 100,
+	}).Result()
+	if err != nil {
+		logger.Error("notify: failed to read held messages", "error", err)
+		return
+	}
+	for _, raw := range due {
+		n, err := database.Rdb.ZRem(ctx, deferredKey, raw).Result()
+		if err != nil || n == 0 {
+			// someone else took it, or we can't tell: leave it to them or the next poll
+			continue
+		}
+		var m Message
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			logger.Error("notify: undecodable held message dropped", "error", err)
+			continue
+		}
+		send(ctx, m)
+	}
+}
+
+// Forget drops the held messages addressed to any of addrs, e.g. the contact
+// details of a deleted account, so they are never sent. It returns how many went.
+func Forget(ctx context.Context, addrs ...string) (int, error) {
+	if database.Rdb == nil || len(addrs) == 0 {
+		return 0, nil
+	}
+	forget := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		if a != "" {
+			forget[a] = true
+		}
+	}
+	held, err := database.Rdb.ZRange(ctx, deferredKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	var drop []interface{}
+	for _, raw := range held {
+		var m Message
+		if json.Unmarshal([]byte(raw), &m) == nil && forget[m.To] {
+			drop = append(drop, raw)
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	n, err := database.Rdb.ZRem(ctx, deferredKey, drop...).Result()
+	return int(n), err
+}
+
+func send(ctx context.Context, m Message) {
+	n := notifier(m.Channel)
+	if n == nil {
+		return
+	}
+	if err := n.Send(ctx, m); err != nil {
+		metrics.ObserveNotification(m.Channel, "failed")
+		logging.FromContext(ctx).Error("notify: send failed",
+			"channel", m.Channel, "order_id", m.OrderID, "error", err)
+		return
+	}
+	metrics.ObserveNotification(m.Channel, "sent")
+}
+
+// QuietUntil reports whether now falls in prefs' quiet hours and, if so, when they
+// end. Quiet hours may wrap midnight (22:00-07:00).
+func QuietUntil(now time.Time, prefs *models.NotificationPrefs) (time.Time, bool) {
+	if prefs.QuietStart == "" || prefs.QuietEnd == "" {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err1 := ParseClock(prefs.QuietStart)
+	end, err2 := ParseClock(prefs.QuietEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return time.Time{}, false
+	}
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	clock := local.Sub(midnight)
+	endToday := midnight.Add(end)
+	switch {
+	case start < end && clock >= start && clock < end:
+		return endToday, true
+	case start > end && clock >= start:
+		// ends tomorrow
+		return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc).Add(end), true
+	case start > end && clock < end:
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+// ParseClock parses an HH:MM time of day into the offset from midnight.
+func ParseClock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || len(h) != 2 || len(m) != 2 || err1 != nil || err2 != nil || hh > 23 || mm > 59 || hh < 0 || mm < 0 {
+		return 0, fmt.Errorf("%q is not a HH:MM time of day", s)
+	}
+	return time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute, nil
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Data is what templates can refer to
+type Data struct {
+	Name    string // the customer's display name, or username if they have none
+	OrderID int
+	Item    string
+	Link    string // password reset link
+}
+
+// messageText is the source of one status's messages in one locale. SMS is kept
+// short enough for a single segment.
+type messageText struct {
+	Subject, Body, SMS string
+}
+
+// texts holds the templates per locale and status, plus the password reset
+// message under PasswordResetTemplate. Statuses without an entry (created) don't
+// notify.
+var texts = map[string]map[string]messageText{
+	"en": {
+		"dispatched": {
+			Subject: "Your order #{{.OrderID}} has shipped",
+			Body:    "Hi {{.Name}},\n\nyour order #{{.OrderID}} ({{.Item}}) has left our warehouse and is on its way.\n",
+			SMS:     "Order #{{.OrderID}} ({{.Item}}) has shipped.",
+		},
+		"in_transit": {
+			Subject: "Your order #{{.OrderID}} is out for delivery",
+			Body:    "Hi {{.Name}},\n\nyour order #{{.OrderID}} ({{.Item}}) is out for delivery and will arrive soon.\n",
+			SMS:     "Order #{{.OrderID}} ({{.Item}}) is out for delivery.",
+		},
+		"delivered": {
+			Subject: "Your order #{{.OrderID}} has arrived",
+			Body:    "Hi {{.Name}},\n\nyour order #{{.OrderID}} ({{.Item}}) has been delivered. Enjoy!\n",
+			SMS:     "Order #{{.OrderID}} ({{.Item}}) has been delivered.",
+		},
+		"cancelled": {
+			Subject: "Your order #{{.OrderID}} was cancelled",
+			Body:    "Hi {{.Name}},\n\nyour order #{{.OrderID}} ({{.Item}}) has been cancelled.\n",
+			SMS:     "Order #{{.OrderID}} ({{.Item}}) was cancelled.",
+		},
+		PasswordResetTemplate: {
+			Subject: "Reset your password",
+			Body:    "Hi {{.Name}},\n\nsomeone asked to reset your password. To choose a new one, use:\n\n{{.Link}}\n\nIf that wasn't you, ignore this message; your password stays as it is.\n",
+			SMS:     "Your password reset code: {{.Link}}",
+		},
+	},
+	"de": {
+		"dispatched": {
+			Subject: "Deine Bestellung #{{.OrderID}} wurde versandt",
+			Body:    "Hallo {{.Name}},\n\ndeine Bestellung #{{.OrderID}} ({{.Item}}) hat unser Lager verlassen und ist unterwegs.\n",
+			SMS:     "Bestellung #{{.OrderID}} ({{.Item}}) wurde versandt.",
+		},
+		"in_transit": {
+			Subject: "Deine Bestellung #{{.OrderID}} wird heute zugestellt",
+			Body:    "Hallo {{.Name}},\n\ndeine Bestellung #{{.OrderID}} ({{.Item}}) ist in der Zustellung und kommt bald an.\n",
+			SMS:     "Bestellung #{{.OrderID}} ({{.Item}}) ist in der Zustellung.",
+		},
+		"delivered": {
+			Subject: "Deine Bestellung #{{.OrderID}} ist angekommen",
+			Body:    "Hallo {{.Name}},\n\ndeine Bestellung #{{.OrderID}} ({{.Item}}) wurde zugestellt. Viel Freude damit!\n",
+			SMS:     "Bestellung #{{.OrderID}} ({{.Item}}) wurde zugestellt.",
+		},
+		"cancelled": {
+			Subject: "Deine Bestellung #{{.OrderID}} wurde storniert",
+			Body:    "Hallo {{.Name}},\n\ndeine Bestellung #{{.OrderID}} ({{.Item}}) wurde storniert.\n",
+			SMS:     "Bestellung #{{.OrderID}} ({{.Item}}) wurde storniert.",
+		},
+		PasswordResetTemplate: {
+			Subject: "Passwort zurücksetzen",
+			Body:    "Hallo {{.Name}},\n\njemand möchte dein Passwort zurücksetzen. Ein neues Passwort kannst du hier wählen:\n\n{{.Link}}\n\nWarst du das nicht, ignoriere diese Nachricht; dein Passwort bleibt unverändert.\n",
+			SMS:     "Dein Code zum Zurücksetzen des Passworts: {{.Link}}",
+		},
+	},
+	"es": {
+		"dispatched": {
+			Subject: "Tu pedido #{{.OrderID}} ha sido enviado",
+			Body:    "Hola {{.Name}}:\n\ntu pedido #{{.OrderID}} ({{.Item}}) ha salido de nuestro almacén y está en camino.\n",
+			SMS:     "El pedido #{{.OrderID}} ({{.Item}}) ha sido enviado.",
+		},
+		"in_transit": {
+			Subject: "Tu pedido #{{.OrderID}} está en reparto",
+			Body:    "Hola {{.Name}}:\n\ntu pedido #{{.OrderID}} ({{.Item}}) está en reparto y llegará pronto.\n",
+			SMS:     "El pedido #{{.OrderID}} ({{.Item}}) está en reparto.",
+		},
+		"delivered": {
+			Subject: "Tu pedido #{{.OrderID}} ha llegado",
+			Body:    "Hola {{.Name}}:\n\ntu pedido #{{.OrderID}} ({{.Item}}) ha sido entregado. ¡Que lo disfrutes!\n",
+			SMS:     "El pedido #{{.OrderID}} ({{.Item}}) ha sido entregado.",
+		},
+		"cancelled": {
+			Subject: "Tu pedido #{{.OrderID}} ha sido cancelado",
+			Body:    "Hola {{.Name}}:\n\ntu pedido #{{.OrderID}} ({{.Item}}) ha sido cancelado.\n",
+			SMS:     "El pedido #{{.OrderID}} ({{.Item}}) ha sido cancelado.",
+		},
+		PasswordResetTemplate: {
+			Subject: "Restablece tu contraseña",
+			Body:    "Hola {{.Name}}:\n\nalguien ha pedido restablecer tu contraseña. Para elegir una nueva, usa:\n\n{{.Link}}\n\nSi no has sido tú, ignora este mensaje; tu contraseña no cambiará.\n",
+			SMS:     "Tu código para restablecer la contraseña: {{.Link}}",
+		},
+	},
+}
+
+// PasswordResetTemplate is the texts entry for password reset messages
+const PasswordResetTemplate = "password_reset"
+
+type compiled struct {
+	subject, body, sms *template.Template
+}
+
+// templates is texts parsed once at startup, so a broken template fails fast
+var templates = func() map[string]map[string]compiled {
+	res := make(map[string]map[string]compiled, len(texts))
+	for locale, byStatus := range texts {
+		res[locale] = make(map[string]compiled, len(byStatus))
+		for status, t := range byStatus {
+			name := locale + "/" + status
+			res[locale][status] = compiled{
+				subject: template.Must(template.New(name + "/subject").Parse(t.Subject)),
+				body:    template.Must(template.New(name + "/body").Parse(t.Body)),
+				sms:     template.Must(template.New(name + "/sms").Parse(t.SMS)),
+			}
+		}
+	}
+	return res
+}()
+
+// Locales returns the supported locales, sorted.
+func Locales() []string {
+	res := make([]string, 0, len(texts))
+	for l := range texts {
+		res = append(res, l)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// SupportedLocale reports whether messages can be rendered in locale.
+func SupportedLocale(locale string) bool {
+	_, ok := texts[locale]
+	return ok
+}
+
+// Render renders the message for status on channel ("email" or "sms") in locale,
+// falling back to the default locale. ok is false if status doesn't notify.
+// SMS messages have no subject.
+func Render(locale, status, channel string, d Data) (subject, body string, ok bool, err error) {
+	byStatus, found := templates[locale]
+	if !found {
+		byStatus = templates[defaultLocale()]
+	}
+	t, found := byStatus[status]
+	if !found {
+		return "", "", false, nil
+	}
+	exec := func(tmpl *template.Template) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, d); err != nil {
+			return "", fmt.Errorf("render %s: %w", tmpl.Name(), err)
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+	if channel == ChannelSMS {
+		body, err = exec(t.sms)
+		return "", body, true, err
+	}
+	if subject, err = exec(t.subject); err != nil {
+		return "", "", true, err
+	}
+	body, err = exec(t.body)
+	return subject, body, true, err
+}
@@ -0,0 +1,73 @@
+package orders
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heart is the heartbeat of one worker loop: a progression goroutine or a batch
+// feeder. The loop beats from its own select, so a loop stuck in e.g. a database
+// call stops beating while the others carry on.
+type heart struct {
+	last atomic.Int64 // unix nanos
+}
+
+var (
+	heartsMu sync.Mutex
+	hearts   = make(map[*heart]struct{})
+
+	// beatInterval is how often a waiting loop beats; zero until ConfigureHeartbeat
+	beatInterval atomic.Int64
+)
+
+// ConfigureHeartbeat sets how often progression and feeder loops report that they
+// are still turning over. Readiness uses HeartbeatAge to detect a stuck loop.
+func ConfigureHeartbeat(interval time.Duration) {
+	beatInterval.Store(int64(interval))
+}
+
+// HeartbeatAge returns how long the quietest running worker loop has gone without
+// a beat: zero when no loop is running, and -1 if ConfigureHeartbeat was never
+// called.
+func HeartbeatAge() time.Duration {
+	if beatInterval.Load() == 0 {
+		return -1
+	}
+	now := time.Now().UnixNano()
+	heartsMu.Lock()
+	defer heartsMu.Unlock()
+	var age time.Duration
+	for h := range hearts {
+		if a := time.Duration(now - h.last.Load()); a > age {
+			age = a
+		}
+	}
+	return age
+}
+
+// newHeart registers a running loop and returns its heart with the ticker the loop
+// beats on. The caller stops both with stopHeart when the loop exits.
+func newHeart() (*heart, *time.Ticker) {
+	h := &heart{}
+	h.beat()
+	heartsMu.Lock()
+	hearts[h] = struct{}{}
+	heartsMu.Unlock()
+	interval := time.Duration(beatInterval.Load())
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return h, time.NewTicker(interval)
+}
+
+func (h *heart) beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func stopHeart(h *heart, t *time.Ticker) {
+	t.Stop()
+	heartsMu.Lock()
+	delete(hearts, h)
+	heartsMu.Unlock()
+}
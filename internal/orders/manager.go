@@ -1,52 +1,149 @@
-
 package orders
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/couriers"
 	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/lock"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
 	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+	"github.com/rajnish-012/delivery-management-system/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // orderController tracks a running progression goroutine for an order
 type orderController struct {
 	stop chan struct{}
+	// done is closed once the goroutine has exited and released its lease
+	done chan struct{}
 }
 
 var (
 	controllers = make(map[int]*orderController) // orderID -> controller
 	mu          sync.Mutex
+
+	// stepDelay is the wait between automatic transitions; see Configure
+	stepDelay = 5 * time.Second
+
+	// workers owns the root context for progression goroutines; see Configure
+	workers = background.New()
+
+	// batchSlots bounds how many QueueProgressions orders progress at once; see Configure
+	batchSlots = make(chan struct{}, 100)
+
+	// batchMaxRows is the largest batch import accepted; see Configure and BatchMaxRows
+	batchMaxRows = 1000
+
+	// lockTTL is the lifetime of an order's progression lease; see Configure
+	lockTTL = 30 * time.Second
 )
 
+// Configure applies the orders section of the runtime configuration and sets the
+// background manager whose root context progression goroutines run under.
+func Configure(cfg config.OrdersConfig, bg *background.Manager) {
+	stepDelay = cfg.StepDelay
+	batchSlots = make(chan struct{}, cfg.BatchConcurrency)
+	batchMaxRows = cfg.BatchMaxRows
+	lockTTL = cfg.LockTTL
+	workers = bg
+}
+
 // lifecycle defines the ordered states an order goes through
 var lifecycle = []string{"created", "dispatched", "in_transit", "delivered"}
 
 // StartProgression launches a goroutine to move the order through lifecycle states.
 // It is safe to call StartProgression multiple times; only one goroutine per order will run.
+// Across replicas the goroutine holds a Redis lease on the order (see acquireLease), so
+// only one instance advances it and publishes its updates.
+// ctx is only used for its tenant scope and for trace and log correlation: the goroutine
+// runs under the background manager's root context, not the caller's, so it survives the
+// HTTP request that started it.
+// Once shutdown has begun no new progression is started; ResumeProgressions picks the
+// order up on the next start.
 func StartProgression(ctx context.Context, orderID int) {
+	startProgression(ctx, orderID, func() {})
+}
+
+// startProgression is StartProgression with a done callback, called exactly once when
+// the progression goroutine exits or if it is never started.
+func startProgression(ctx context.Context, orderID int, done func()) {
 	mu.Lock()
 	// Already running?
 	if _, ok := controllers[orderID]; ok {
 		mu.Unlock()
+		done()
 		return
 	}
-	c := &orderController{stop: make(chan struct{})}
+	c := &orderController{stop: make(chan struct{}), done: make(chan struct{})}
 	controllers[orderID] = c
 	mu.Unlock()
 
-	go func() {
+	// the progression outlives the request, so it gets its own trace linked back to the creating one
+	link := trace.LinkFromContext(ctx)
+	// keep the creating request's logger (and its request_id) for correlation
+	logger := logging.FromContext(ctx).With("order_id", orderID)
+	scoped := ctx
+
+	metrics.ActiveProgressions.Inc()
+	err := workers.Go(func(root context.Context) {
+		root = database.CopyScope(root, scoped)
+		ctx, span := tracing.Tracer().Start(logging.WithContext(root, logger), "orders.progression",
+			trace.WithNewRoot(),
+			trace.WithLinks(link),
+			trace.WithAttributes(attribute.Int("order.id", orderID)),
+		)
+		defer span.End()
+
 		defer func() {
 			mu.Lock()
-			delete(controllers, orderID)
+			// CancelProgression may already have replaced us
+			if controllers[orderID] == c {
+				delete(controllers, orderID)
+			}
 			mu.Unlock()
+			metrics.ActiveProgressions.Dec()
+			done()
+			close(c.done)
 		}()
 
-		// fetch current status
-		ord, err := models.GetOrderByID(ctx, orderID)
+		h, beats := newHeart()
+		defer stopHeart(h, beats)
+
+		lease, ok := acquireLease(ctx, c, orderID, h, beats)
+		if !ok {
+			return
+		}
+		var fence int64
+		var lost <-chan struct{} // nil without Redis: never fires
+		if lease != nil {
+			defer func() {
+				if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+					logger.Warn("progression: failed to release lease", "error", err)
+				}
+			}()
+			fence = lease.Token()
+			lost = lease.Lost()
+		}
+
+		// fetch current status; uncached, since the first transition compares against it
+		ord, err := models.GetOrderUncached(ctx, orderID)
 		if err != nil {
+			logger.Error("progression: failed to load order", "error", err)
+			return
+		}
+
+		if ord.Status == "cancelled" {
 			return
 		}
 
@@ -62,62 +159,226 @@ func StartProgression(ctx context.Context, orderID int) {
 			// unknown status -> start from beginning
 			idx = 0
 			// ensure DB is consistent
-			_ = models.UpdateOrderStatus(ctx, orderID, lifecycle[idx])
+			reset, err := models.UpdateOrderStatus(ctx, orderID, ord.Status, lifecycle[idx], fence)
+			if err != nil || reset == nil {
+				logger.Error("progression: failed to reset unknown status",
+					"status", ord.Status, "error", err)
+				return
+			}
+			metrics.ObserveTransition(ord.Status, lifecycle[idx])
 			publishUpdate(ctx, orderID, lifecycle[idx])
 		}
 
-		// progression loop
+		// progression loop; it beats while waiting between transitions (orders.step_delay)
+		next := time.After(stepDelay)
 		for idx < len(lifecycle)-1 {
-			// Wait time between state transitions.
-			// For production/testing, expose this via config/env.
 			select {
-			case <-time.After(5 * time.Second):
-				// Re-check order status from DB (in case it was cancelled externally)
-				ordNow, err := models.GetOrderByID(ctx, orderID)
-				if err != nil {
-					return
-				}
-				if ordNow.Status == "cancelled" {
-					// publish that it's cancelled and stop progressing
-					publishUpdate(ctx, orderID, "cancelled")
-					return
-				}
-
-				// advance to next state
+			case <-beats.C:
+				h.beat()
+			case <-next:
+				// advance to next state; the write is compare-and-set against the
+				// status we last wrote, so a concurrent cancel or override wins
+				prevStatus := lifecycle[idx]
 				idx++
 				nextStatus := lifecycle[idx]
-				if err := models.UpdateOrderStatus(ctx, orderID, nextStatus); err != nil {
+				tctx, tspan := tracing.Tracer().Start(ctx, "orders.transition", trace.WithAttributes(
+					attribute.String("order.status.from", prevStatus),
+					attribute.String("order.status.to", nextStatus),
+				))
+				updated, err := models.UpdateOrderStatus(tctx, orderID, prevStatus, nextStatus, fence)
+				if err != nil {
 					// if update fails, stop progression
+					logger.Error("progression: failed to update status",
+						"from", prevStatus, "to", nextStatus, "error", err)
+					tspan.RecordError(err)
+					tspan.End()
 					return
 				}
-				publishUpdate(ctx, orderID, nextStatus)
+				if updated == nil {
+					// lost the CAS: the order was cancelled or overridden, or a newer
+					// lease holder moved it on. Whoever changed it owns it now.
+					logger.Info("progression: order changed externally, stopping", "expected", prevStatus)
+					tspan.SetAttributes(attribute.Bool("order.status.cas_lost", true))
+					tspan.End()
+					return
+				}
+				metrics.ObserveTransition(prevStatus, nextStatus)
+				logger.Info("progression: status changed", "from", prevStatus, "to", nextStatus)
+				publishUpdate(tctx, orderID, nextStatus)
+				tspan.End()
+				h.beat()
+				next = time.After(stepDelay)
 
 			case <-c.stop:
 				// stopped by cancellation/override
+				logger.Debug("progression: stopped")
+				return
+			case <-lost:
+				// another replica may hold the lease now; the fence refuses any write we race it with
+				logger.Warn("progression: lease lost", "status", lifecycle[idx])
+				return
+			case <-workers.Stopping():
+				// between transitions, so nothing is half-done; resumed on next start
+				logger.Info("progression: paused for shutdown", "status", lifecycle[idx])
 				return
 			case <-ctx.Done():
+				logger.Warn("progression: context done before delivery",
+					"status", lifecycle[idx], "error", ctx.Err())
 				return
 			}
 		}
-	}()
+	})
+	if err != nil {
+		mu.Lock()
+		if controllers[orderID] == c {
+			delete(controllers, orderID)
+		}
+		mu.Unlock()
+		metrics.ActiveProgressions.Dec()
+		done()
+		close(c.done)
+		logger.Warn("progression not started", "error", err)
+	}
+}
+
+// leaseKey is the Redis key of an order's progression lease
+func leaseKey(orderID int) string {
+	return "lock:order:" + strconv.Itoa(orderID)
+}
+
+// acquireLease takes the order's progression lease. While another replica holds it
+// the call waits, retrying every lockTTL, so this replica takes over if the holder
+// dies; it gives up once the order is finished or the progression is stopped. ok is
+// false if progression should not continue. Without Redis (a single instance) the
+// lease is nil and ok is true. The progression's heart h beats while it waits.
+func acquireLease(ctx context.Context, c *orderController, orderID int, h *heart, beats *time.Ticker) (lease *lock.Lease, ok bool) {
+	if database.Rdb == nil {
+		return nil, true
+	}
+	logger := logging.FromContext(ctx)
+	for {
+		lease, err := lock.Acquire(ctx, leaseKey(orderID), lockTTL)
+		if err == nil {
+			return lease, true
+		}
+		if errors.Is(err, lock.ErrNotAcquired) {
+			ord, err := models.GetOrderUncached(ctx, orderID)
+			if err != nil {
+				logger.Error("progression: failed to load order", "error", err)
+				return nil, false
+			}
+			if ord.Status == "delivered" || ord.Status == "cancelled" {
+				return nil, false
+			}
+			logger.Debug("progression: lease held by another replica, standing by")
+		} else {
+			// without the lease two replicas could progress the order; wait for Redis
+			logger.Warn("progression: failed to acquire lease", "error", err)
+		}
+		retry := time.After(lockTTL)
+	wait:
+		for {
+			select {
+			case <-beats.C:
+				h.beat()
+			case <-retry:
+				break wait
+			case <-c.stop:
+				return nil, false
+			case <-workers.Stopping():
+				return nil, false
+			case <-ctx.Done():
+				return nil, false
+			}
+		}
+		h.beat()
+	}
 }
 
-// CancelProgression stops any running progression goroutine for an order.
-func CancelProgression(orderID int) {
+// BatchMaxRows returns the most orders a single batch import may contain.
+func BatchMaxRows() int {
+	return batchMaxRows
+}
+
+// QueueProgressions starts progression for many orders at once, e.g. after a batch
+// import. A single feeder goroutine starts them as slots free up, so at most
+// orders.batch_concurrency queued progressions run at a time and a large batch
+// doesn't spawn a goroutine per order up front. Orders still queued at shutdown are
+// picked up by ResumeProgressions on the next start.
+func QueueProgressions(ctx context.Context, orderIDs []int) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	slots := batchSlots
+	return workers.Go(func(context.Context) {
+		h, beats := newHeart()
+		defer stopHeart(h, beats)
+		for _, id := range orderIDs {
+		wait:
+			for {
+				select {
+				case slots <- struct{}{}:
+					break wait
+				case <-beats.C:
+					h.beat()
+				case <-workers.Stopping():
+					return
+				}
+			}
+			startProgression(ctx, id, func() { <-slots })
+			h.beat()
+		}
+	})
+}
+
+// ResumeProgressions restarts progression for every order that is not yet delivered or
+// cancelled, e.g. orders whose goroutines were drained by a previous shutdown.
+func ResumeProgressions(ctx context.Context) (int, error) {
+	active, err := models.ListActiveOrders(database.WithSystem(ctx))
+	if err != nil {
+		return 0, err
+	}
+	for _, o := range active {
+		StartProgression(database.WithTenant(ctx, o.TenantID), o.ID)
+	}
+	return len(active), nil
+}
+
+// CancelProgression stops any running progression goroutine for an order and
+// waits, until ctx is done, for it to exit. The goroutine releases its lease on the
+// way out, so a progression started next takes the lease at once instead of
+// waiting out orders.lock_ttl.
+func CancelProgression(ctx context.Context, orderID int) {
 	mu.Lock()
-	defer mu.Unlock()
-	if c, ok := controllers[orderID]; ok {
+	c, ok := controllers[orderID]
+	if ok {
 		// closing stop channel signals goroutine to terminate
 		close(c.stop)
 		delete(controllers, orderID)
 	}
+	mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
 }
 
-// publishUpdate publishes a JSON payload to Redis channel orders:updates
+// publishUpdate publishes a JSON payload to Redis channel orders:updates, queues
+// the customer's notifications, and replans the route of the order's courier and
+// refreshes ETAs, which publishes ETA changes on the same channel. Every status
+// change goes through here exactly once, on the replica that made it.
 func publishUpdate(ctx context.Context, orderID int, status string) {
+	notify.OrderStatusChanged(ctx, orderID, status)
+	couriers.OrderChanged(ctx, orderID)
 	if database.Rdb != nil {
 		payload := fmt.Sprintf(`{"order_id":%d,"status":"%s"}`, orderID, status)
-		_ = database.Rdb.Publish(ctx, "orders:updates", payload).Err()
+		if err := database.Rdb.Publish(ctx, "orders:updates", payload).Err(); err != nil {
+			logging.FromContext(ctx).Error("failed to publish order update",
+				"order_id", orderID, "status", status, "error", err)
+		}
 	}
 }
 
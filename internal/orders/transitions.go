@@ -0,0 +1,49 @@
+package orders
+
+import "fmt"
+
+// transitions are the moves orders make on their own: progression along the
+// lifecycle, and cancellation of anything not yet delivered
+var transitions = map[string][]string{
+	"created":    {"dispatched", "cancelled"},
+	"dispatched": {"in_transit", "cancelled"},
+	"in_transit": {"delivered", "cancelled"},
+}
+
+// overrides are the extra moves an admin may force, with a reason, to repair an
+// order after e.g. a courier app glitch: any lifecycle status can be set from any
+// other, and a cancelled order can be reinstated. A delivered order still can't be
+// cancelled.
+var overrides = map[string][]string{
+	"created":    {"in_transit", "delivered"},
+	"dispatched": {"created", "delivered"},
+	"in_transit": {"created", "dispatched"},
+	"delivered":  {"created", "dispatched", "in_transit"},
+	"cancelled":  {"created", "dispatched", "in_transit"},
+}
+
+// CheckTransition returns an error unless an order may move from one status to the
+// other. override allows the moves in overrides as well.
+func CheckTransition(from, to string, override bool) error {
+	if allowed(transitions[from], to) || (override && allowed(overrides[from], to)) {
+		return nil
+	}
+	if !override && allowed(overrides[from], to) {
+		return fmt.Errorf("cannot move from %s to %s without override", from, to)
+	}
+	return fmt.Errorf("cannot move from %s to %s", from, to)
+}
+
+// Active reports whether an order in status still progresses on its own.
+func Active(status string) bool {
+	return status != "delivered" && status != "cancelled"
+}
+
+func allowed(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Package privacy erases and exports the personal data held about a user, and
+// runs the retention job that purges personal data nobody needs any more.
+package privacy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+)
+
+// DeleteAccount deletes a user in ctx's tenant (see models.DeleteUser), logs them
+// out everywhere and drops notifications still held for their contact details. It
+// returns models.ErrActiveOrders if they have orders in progress. Deleting an
+// already deleted account only repeats the logout, so a call that failed after
+// the database step can be retried.
+func DeleteAccount(ctx context.Context, userID int) error {
+	before, err := models.DeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := notify.Forget(ctx, before.Email, before.Phone); err != nil {
+		return err
+	}
+	return auth.RevokeSessions(ctx, userID)
+}
+
+// Export is everything held about a user, as returned by the data export
+type Export struct {
+	ExportedAt    time.Time                 `json:"exported_at"`
+	Account       Account                   `json:"account"`
+	Notifications *models.NotificationPrefs `json:"notification_preferences"`
+	TwoFactor     TwoFactor                 `json:"two_factor"`
+	Orders        []*models.Order           `json:"orders"`
+	// StatusChanges are the manual status changes admins made to the orders
+	StatusChanges []*models.StatusChange `json:"status_changes"`
+	// Courier is the last reported position and planned route of a courier, or nil
+	Courier *models.Courier `json:"courier"`
+}
+
+// Account is the user's own record. Password hashes and second factor secrets are
+// left out: they are credentials, not data about the user.
+type Account struct {
+	ID          int        `json:"id"`
+	TenantID    int        `json:"tenant_id"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role"`
+	DisplayName string     `json:"display_name"`
+	Email       string     `json:"email"`
+	Phone       string     `json:"phone"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+type TwoFactor struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// ExportAccount collects everything held about a user in ctx's tenant. Orders are
+// read past the cache so the export reflects the database.
+func ExportAccount(ctx context.Context, userID int) (*Export, error) {
+	u, err := models.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	e := &Export{
+		ExportedAt: time.Now().UTC(),
+		Account: Account{
+			ID: u.ID, TenantID: u.TenantID, Username: u.Username, Role: u.Role,
+			DisplayName: u.DisplayName, Email: u.Email, Phone: u.Phone,
+			CreatedAt: u.CreatedAt, LastLoginAt: u.LastLoginAt, DisabledAt: u.DisabledAt, DeletedAt: u.DeletedAt,
+		},
+		TwoFactor: TwoFactor{Enabled: u.TOTPEnabled},
+	}
+	if e.Notifications, err = models.GetNotificationPrefs(ctx, userID); err != nil {
+		return nil, err
+	}
+	if e.TwoFactor.RecoveryCodesLeft, err = models.RecoveryCodesLeft(ctx, userID); err != nil {
+		return nil, err
+	}
+	if e.Orders, err = models.ListOrders(ctx, models.OrderFilter{CustomerID: userID}); err != nil {
+		return nil, err
+	}
+	if e.StatusChanges, err = models.ListStatusChangesByCustomer(ctx, userID); err != nil {
+		return nil, err
+	}
+	if e.Courier, err = models.GetCourier(ctx, userID); errors.Is(err, pgx.ErrNoRows) {
+		e.Courier = nil
+	} else if err != nil {
+		return nil, err
+	}
+	if e.Orders == nil {
+		e.Orders = []*models.Order{}
+	}
+	if e.StatusChanges == nil {
+		e.StatusChanges = []*models.StatusChange{}
+	}
+	return e, nil
+}
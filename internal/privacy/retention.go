@@ -0,0 +1,133 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/lock"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+// retentionLock is held by the replica running a purge
+const retentionLock = "lock:retention"
+
+// accountBatch bounds the inactive accounts deleted by one purge; the rest wait
+// for the next run
+const accountBatch = 100
+
+// positionBatch is how many orders one statement clears the positions of
+const positionBatch = 1000
+
+var (
+	mu      sync.RWMutex
+	cfg     config.RetentionConfig
+	workers *background.Manager
+)
+
+// Configure sets the retention periods and the background manager the job runs
+// under. Nothing is purged until Start.
+func Configure(c config.RetentionConfig, bg *background.Manager) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	workers = bg
+}
+
+// Start runs the retention job every configured interval. With several replicas
+// the run is guarded by a Redis lease, so only one of them purges at a time.
+func Start() error {
+	mu.RLock()
+	c, bg := cfg, workers
+	mu.RUnlock()
+	return bg.Go(func(ctx context.Context) {
+		t := time.NewTicker(c.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				runLocked(ctx, c, now)
+			case <-bg.Stopping():
+				return
+			}
+		}
+	})
+}
+
+// runLocked purges under the retention lease and skips the run if another replica
+// holds it.
+func runLocked(ctx context.Context, c config.RetentionConfig, now time.Time) {
+	logger := logging.FromContext(ctx)
+	lease, err := lock.Acquire(ctx, retentionLock, c.Interval)
+	if errors.Is(err, lock.ErrNotAcquired) {
+		return
+	}
+	if err != nil {
+		logger.Error("retention: failed to acquire lease", "error", err)
+		return
+	}
+	defer lease.Release(ctx)
+	if _, err := Purge(ctx, c, now); err != nil {
+		logger.Error("retention: purge failed", "error", err)
+	}
+}
+
+// PurgeResult counts what one purge removed
+type PurgeResult struct {
+	Credentials int64
+	Accounts    int
+	Positions   int
+}
+
+// Purge removes, across all tenants, the spent reset tokens and recovery codes
+// older than c.Tokens. If c.InactiveAccounts is set it deletes customer accounts
+// idle for longer than that, and if c.OrderPositions is set it clears the positions
+// of orders finished longer ago than that.
+func Purge(ctx context.Context, c config.RetentionConfig, now time.Time) (PurgeResult, error) {
+	var res PurgeResult
+	sys := database.WithSystem(ctx)
+	n, err := models.PurgeSpentCredentials(sys, now.Add(-c.Tokens))
+	if err != nil {
+		return res, err
+	}
+	res.Credentials = n
+	if c.InactiveAccounts > 0 {
+		idle, err := models.InactiveCustomers(sys, now.Add(-c.InactiveAccounts), accountBatch)
+		if err != nil {
+			return res, err
+		}
+		for _, u := range idle {
+			err := DeleteAccount(database.WithTenant(ctx, u.TenantID), u.ID)
+			if errors.Is(err, models.ErrActiveOrders) {
+				// an order came in since the query; the account is no longer idle
+				continue
+			}
+			if err != nil {
+				return res, err
+			}
+			res.Accounts++
+		}
+	}
+	if c.OrderPositions > 0 {
+		for {
+			n, err := models.PurgeOrderPositions(sys, now.Add(-c.OrderPositions), positionBatch)
+			res.Positions += n
+			if err != nil {
+				return res, err
+			}
+			if n < positionBatch {
+				break
+			}
+		}
+	}
+	if res.Credentials > 0 || res.Accounts > 0 || res.Positions > 0 {
+		logging.FromContext(ctx).Info("retention: purged personal data",
+			"credentials", res.Credentials, "accounts", res.Accounts, "order_positions", res.Positions)
+	}
+	return res, nil
+}
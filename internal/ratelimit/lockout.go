@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+// Login lockout state, per username and client IP and per username alone:
+//
+//	login:fail:<username>:<ip>  failure count, expires FailureWindow after the last failure
+//	login:lock:<username>:<ip>  present while locked out; its TTL is the remaining lockout
+//	login:fail:<username>       failures from every address
+//	login:lock:<username>       locks the username out of every address
+//
+// Each failure past a threshold doubles the lockout, capped at MaxDuration. The
+// per-address lock trips at Threshold, so one address guessing passwords is
+// stopped early without locking the account out of the addresses its owner uses.
+// The per-username lock trips at the higher AccountThreshold and stops guessing
+// spread over many addresses. That one does lock the owner out too: it is the
+// price of bounding a distributed attack, and why its threshold is higher.
+
+func failKey(username, ip string) string { return "login:fail:" + username + ":" + ip }
+func lockKey(username, ip string) string { return "login:lock:" + username + ":" + ip }
+func accountFailKey(username string) string { return "login:fail:" + username }
+func accountLockKey(username string) string { return "login:lock:" + username }
+
+// LockedOut reports how long username remains locked out for logins from ip, or 0
+// if it isn't.
+func LockedOut(ctx context.Context, username, ip string) (time.Duration, error) {
+	if !current().Enabled || database.Rdb == nil {
+		return 0, nil
+	}
+	var addr, account *redis.DurationCmd
+	_, err := database.Rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		addr = p.PTTL(ctx, lockKey(username, ip))
+		account = p.PTTL(ctx, accountLockKey(username))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// negative TTLs are -2: no lock, -1: no expiry (shouldn't happen)
+	return maxDuration(0, addr.Val(), account.Val()), nil
+}
+
+// RecordLoginFailure counts a failed login for username from ip and starts or
+// extends a lockout once a threshold is reached. It returns the longest lockout
+// now in effect for ip (0 if none).
+func RecordLoginFailure(ctx context.Context, username, ip string) (time.Duration, error) {
+	c := current()
+	if !c.Enabled || database.Rdb == nil {
+		return 0, nil
+	}
+	lo := c.Lockout
+	addr, err := recordFailure(ctx, failKey(username, ip), lockKey(username, ip), lo.Threshold, lo)
+	if err != nil {
+		return 0, err
+	}
+	account, err := recordFailure(ctx, accountFailKey(username), accountLockKey(username), lo.AccountThreshold, lo)
+	if err != nil {
+		return 0, err
+	}
+	return maxDuration(addr, account), nil
+}
+
+// recordFailure counts a failure under fail and sets lock for the resulting
+// lockout once threshold failures are reached
+func recordFailure(ctx context.Context, fail, lock string, threshold int, lo config.LockoutConfig) (time.Duration, error) {
+	var incr *redis.IntCmd
+	_, err := database.Rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.Incr(ctx, fail)
+		p.PExpire(ctx, fail, lo.FailureWindow)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	failures := int(incr.Val())
+	if failures < threshold {
+		return 0, nil
+	}
+
+	d := lo.BaseDuration
+	for i := threshold; i < failures && d < lo.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > lo.MaxDuration {
+		d = lo.MaxDuration
+	}
+	if err := database.Rdb.Set(ctx, lock, failures, d).Err(); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+// ResetLoginFailures clears the failure counts of username after a successful
+// login from ip. A lockout already in effect runs out on its own.
+func ResetLoginFailures(ctx context.Context, username, ip string) error {
+	if !current().Enabled || database.Rdb == nil {
+		return nil
+	}
+	return database.Rdb.Del(ctx, failKey(username, ip), accountFailKey(username)).Err()
+}
+
+func maxDuration(ds ...time.Duration) time.Duration {
+	var m time.Duration
+	for _, d := range ds {
+		if d > m {
+			m = d
+		}
+	}
+	return m
+}
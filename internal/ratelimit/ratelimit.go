@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+)
+
+// slidingWindow atomically trims the request log to the window, then admits the
+// request if there is room. Returns {allowed, remaining, ms until a slot frees}.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', key, now, ARGV[4])
+  redis.call('PEXPIRE', key, window)
+  count = count + 1
+  allowed = 1
+end
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+  reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// Result is the outcome of one Allow call
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the oldest request leaves the window
+	Reset time.Duration
+}
+
+var (
+	mu  sync.RWMutex
+	cfg config.LimitsConfig
+)
+
+// Configure sets the rules used by Middleware and Lockout. Until it is called
+// rate limiting is disabled.
+func Configure(c config.LimitsConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+func current() config.LimitsConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg
+}
+
+// Allow records a request for key and reports whether it fits within rule.
+func Allow(ctx context.Context, key string, rule config.Rule) (Result, error) {
+	if database.Rdb == nil {
+		return Result{}, errors.New("redis not initialized")
+	}
+	now := time.Now().UnixMilli()
+	res, err := slidingWindow.Run(ctx, database.Rdb, []string{key},
+		now, rule.Window.Milliseconds(), rule.Requests, strconv.FormatInt(now, 10)+"-"+nonce(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:   res[0] == 1,
+		Limit:     rule.Requests,
+		Remaining: int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// Key extracts one rate limit dimension from a request. Fn returns "" when the
+// dimension doesn't apply (e.g. no username in the body), which skips that rule.
+type Key struct {
+	Name string // ip, username or user; matches the keys in config groups
+	Fn   func(r *http.Request) string
+}
+
+// IP keys requests by client address.
+var IP = Key{Name: "ip", Fn: ClientIP}
+
+// Middleware enforces the group's rule for each key. Every matching rule is
+// charged; the tightest remaining budget is reported in the X-RateLimit headers.
+// If Redis is unavailable requests are let through and the error is logged.
+func Middleware(group string, keys ...Key) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := current()
+			if !c.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var tightest *Result
+			for _, k := range keys {
+				rule, ok := c.Groups[group][k.Name]
+				if !ok {
+					continue
+				}
+				id := k.Fn(r)
+				if id == "" {
+					continue
+				}
+				res, err := Allow(r.Context(), "rl:"+group+":"+k.Name+":"+id, rule)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("rate limiter unavailable, allowing request",
+						"group", group, "key", k.Name, "error", err)
+					continue
+				}
+				if !res.Allowed {
+					TooManyRequests(w, &res)
+					return
+				}
+				if tightest == nil || res.Remaining < tightest.Remaining {
+					tightest = &res
+				}
+			}
+			if tightest != nil {
+				setHeaders(w, tightest)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setHeaders(w http.ResponseWriter, res *Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+// TooManyRequests writes a 429 with the rate limit headers and Retry-After.
+func TooManyRequests(w http.ResponseWriter, res *Result) {
+	setHeaders(w, res)
+	RetryLater(w, res.Reset)
+}
+
+// RetryLater writes a 429 telling the client to retry after d.
+func RetryLater(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d)))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
+
+func ceilSeconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
+
+// ClientIP returns the caller's IP, honouring X-Forwarded-For only when trust_proxy is set.
+func ClientIP(r *http.Request) string {
+	if current().TrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func nonce() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
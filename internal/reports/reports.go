@@ -0,0 +1,73 @@
+// Package reports caches computed admin reports in Redis.
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+)
+
+var (
+	mu  sync.RWMutex
+	cfg config.ReportsConfig
+)
+
+// Configure sets the cache TTL. Until it is called reports are not cached.
+func Configure(c config.ReportsConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+func ttl() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.CacheTTL
+}
+
+// Report is the envelope every report is returned in
+type Report struct {
+	Name        string      `json:"report"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Data        interface{} `json:"data"`
+}
+
+// Get returns the JSON-encoded report cached under key, computing and caching it on
+// a miss. hit reports whether it came from the cache. Redis errors are logged and
+// the report is computed directly, so a cache outage never fails a report.
+func Get(ctx context.Context, name, key string, compute func(context.Context) (interface{}, error)) (body []byte, hit bool, err error) {
+	t := ttl()
+	cacheKey := "report:" + name + ":" + key
+	logger := logging.FromContext(ctx)
+	if t > 0 && database.Rdb != nil {
+		b, err := database.Rdb.Get(ctx, cacheKey).Bytes()
+		switch {
+		case err == nil:
+			return b, true, nil
+		case !errors.Is(err, redis.Nil):
+			logger.Warn("report cache read failed", "report", name, "error", err)
+		}
+	}
+
+	data, err := compute(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	body, err = json.Marshal(Report{Name: name, GeneratedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return nil, false, err
+	}
+	if t > 0 && database.Rdb != nil {
+		if err := database.Rdb.Set(ctx, cacheKey, body, t).Err(); err != nil {
+			logger.Warn("report cache write failed", "report", name, "error", err)
+		}
+	}
+	return body, false, nil
+}
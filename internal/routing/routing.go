@@ -0,0 +1,277 @@
+// Package routing orders the stops of a courier's run. It is a local heuristic
+// meant for the handful of parcels one courier carries: a nearest-neighbour tour
+// improved by 2-opt. Every pickup stays before its order's drop-off, and time
+// windows are met where possible. Travel times are great-circle distances at a
+// constant speed; no external routing service is involved.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+)
+
+// maxPasses bounds the 2-opt improvement passes over a route
+const maxPasses = 50
+
+// Kind is what happens at a stop
+type Kind string
+
+const (
+	Pickup  Kind = "pickup"
+	Dropoff Kind = "dropoff"
+)
+
+// Stop is a place the courier has to visit for an order
+type Stop struct {
+	OrderID  int       `json:"order_id"`
+	Kind     Kind      `json:"kind"`
+	Position geo.Point `json:"position"`
+	// Earliest and Latest bound the arrival, if set. A courier arriving before
+	// Earliest waits; arriving after Latest is late but still allowed.
+	Earliest *time.Time `json:"earliest,omitempty"`
+	Latest   *time.Time `json:"latest,omitempty"`
+}
+
+// PlannedStop is a stop with the time the courier is expected to reach it
+type PlannedStop struct {
+	Stop
+	Arrival time.Time `json:"arrival"`
+	// Late is set when Arrival is after the stop's Latest
+	Late bool `json:"late,omitempty"`
+}
+
+// Route is an ordered run of stops
+type Route struct {
+	Stops []PlannedStop `json:"stops"`
+	// Distance is the length of the run in metres, from the start position
+	Distance float64 `json:"distance_m"`
+	// Finish is when the last stop is done
+	Finish    time.Time `json:"finish"`
+	PlannedAt time.Time `json:"planned_at"`
+}
+
+// Options are the inputs of a plan besides the stops
+type Options struct {
+	// Start is where the courier is; nil starts the run at its first stop
+	Start *geo.Point
+	// At is when the run starts
+	At time.Time
+	// Speed is the courier's average speed in metres per second
+	Speed float64
+	// StopTime is spent at every stop
+	StopTime time.Duration
+}
+
+// ErrInvalidStops means the stops can't form a run, e.g. an order with two pickups
+var ErrInvalidStops = errors.New("invalid stops")
+
+var errNoSpeed = errors.New("routing: speed must be positive")
+
+// Plan returns the stops in the order the courier should visit them. A drop-off
+// whose pickup isn't among stops is treated as already picked up.
+func Plan(stops []Stop, o Options) (*Route, error) {
+	if o.Speed <= 0 {
+		return nil, errNoSpeed
+	}
+	p := &planner{stops: stops, opts: o, pickupOf: make([]int, len(stops))}
+	pickups := make(map[int]int)
+	dropoffs := make(map[int]bool)
+	for i, s := range stops {
+		p.pickupOf[i] = -1
+		switch s.Kind {
+		case Pickup:
+			if _, dup := pickups[s.OrderID]; dup {
+				return nil, fmt.Errorf("%w: order %d is picked up twice", ErrInvalidStops, s.OrderID)
+			}
+			pickups[s.OrderID] = i
+		case Dropoff:
+			if dropoffs[s.OrderID] {
+				return nil, fmt.Errorf("%w: order %d is dropped off twice", ErrInvalidStops, s.OrderID)
+			}
+			dropoffs[s.OrderID] = true
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidStops, s.Kind)
+		}
+	}
+	for i, s := range stops {
+		if s.Kind == Dropoff {
+			if j, ok := pickups[s.OrderID]; ok {
+				p.pickupOf[i] = j
+			}
+		}
+	}
+
+	seq := p.nearestNeighbour()
+	seq = p.twoOpt(seq)
+	return p.route(seq), nil
+}
+
+// Follow times a run that visits stops in the given order, without reordering
+// them, e.g. to re-time a planned route from where the courier is now
+func Follow(stops []Stop, o Options) (*Route, error) {
+	if o.Speed <= 0 {
+		return nil, errNoSpeed
+	}
+	seq := make([]int, len(stops))
+	for i := range seq {
+		seq[i] = i
+	}
+	p := &planner{stops: stops, opts: o}
+	return p.route(seq), nil
+}
+
+// planner holds a plan's stops and options while sequences are built and scored
+type planner struct {
+	stops []Stop
+	opts  Options
+	// pickupOf is the index of each drop-off's pickup, or -1
+	pickupOf []int
+}
+
+// travel is how long it takes to cover a distance in metres
+func (p *planner) travel(d float64) time.Duration {
+	return time.Duration(d / p.opts.Speed * float64(time.Second))
+}
+
+// leg returns the distance from the previous position (nil at the start) to stop i
+func (p *planner) leg(from *geo.Point, i int) float64 {
+	if from == nil {
+		return 0
+	}
+	return geo.Distance(*from, p.stops[i].Position)
+}
+
+// arrive returns when stop i is reached after leaving at t having travelled d
+// metres, and when service there can start
+func (p *planner) arrive(t time.Time, d float64, i int) (arrival, start time.Time) {
+	arrival = t.Add(p.travel(d))
+	start = arrival
+	if e := p.stops[i].Earliest; e != nil && start.Before(*e) {
+		start = *e
+	}
+	return arrival, start
+}
+
+// nearestNeighbour builds a first sequence by always going to the stop that can be
+// served soonest among those whose pickup is done, breaking ties by distance
+func (p *planner) nearestNeighbour() []int {
+	n := len(p.stops)
+	done := make([]bool, n)
+	seq := make([]int, 0, n)
+	pos, t := p.opts.Start, p.opts.At
+	for len(seq) < n {
+		best, bestStart, bestDist := -1, time.Time{}, 0.0
+		for i := 0; i < n; i++ {
+			if done[i] || (p.pickupOf[i] >= 0 && !done[p.pickupOf[i]]) {
+				continue
+			}
+			d := p.leg(pos, i)
+			_, start := p.arrive(t, d, i)
+			if best < 0 || start.Before(bestStart) || (start.Equal(bestStart) && d < bestDist) {
+				best, bestStart, bestDist = i, start, d
+			}
+		}
+		done[best] = true
+		seq = append(seq, best)
+		pos = &p.stops[best].Position
+		t = bestStart.Add(p.opts.StopTime)
+	}
+	return seq
+}
+
+// cost scores a sequence: total lateness first, then finish time, then distance
+type cost struct {
+	late     time.Duration
+	finish   time.Time
+	distance float64
+}
+
+func (c cost) less(o cost) bool {
+	if c.late != o.late {
+		return c.late < o.late
+	}
+	if !c.finish.Equal(o.finish) {
+		return c.finish.Before(o.finish)
+	}
+	return c.distance < o.distance
+}
+
+func (p *planner) score(seq []int) cost {
+	var c cost
+	pos, t := p.opts.Start, p.opts.At
+	for _, i := range seq {
+		d := p.leg(pos, i)
+		c.distance += d
+		arrival, start := p.arrive(t, d, i)
+		if l := p.stops[i].Latest; l != nil && arrival.After(*l) {
+			c.late += arrival.Sub(*l)
+		}
+		pos = &p.stops[i].Position
+		t = start.Add(p.opts.StopTime)
+	}
+	c.finish = t
+	return c
+}
+
+// feasible reports whether seq keeps every pickup before its drop-off
+func (p *planner) feasible(seq []int) bool {
+	at := make([]int, len(p.stops))
+	for k, i := range seq {
+		at[i] = k
+	}
+	for i, j := range p.pickupOf {
+		if j >= 0 && at[j] > at[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// twoOpt reverses segments of seq while that lowers its cost and keeps it feasible
+func (p *planner) twoOpt(seq []int) []int {
+	best := p.score(seq)
+	cand := make([]int, len(seq))
+	for pass := 0; pass < maxPasses; pass++ {
+		improved := false
+		for i := 0; i < len(seq)-1; i++ {
+			for j := i + 1; j < len(seq); j++ {
+				copy(cand, seq)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					cand[a], cand[b] = cand[b], cand[a]
+				}
+				if !p.feasible(cand) {
+					continue
+				}
+				if c := p.score(cand); c.less(best) {
+					best = c
+					seq, cand = cand, seq
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return seq
+}
+
+// route lays out seq with arrival times
+func (p *planner) route(seq []int) *Route {
+	r := &Route{Stops: make([]PlannedStop, 0, len(seq)), PlannedAt: p.opts.At}
+	pos, t := p.opts.Start, p.opts.At
+	for _, i := range seq {
+		d := p.leg(pos, i)
+		r.Distance += d
+		arrival, start := p.arrive(t, d, i)
+		s := p.stops[i]
+		r.Stops = append(r.Stops, PlannedStop{Stop: s, Arrival: arrival, Late: s.Latest != nil && arrival.After(*s.Latest)})
+		pos = &s.Position
+		t = start.Add(p.opts.StopTime)
+	}
+	r.Finish = t
+	return r
+}
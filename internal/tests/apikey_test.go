@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+func TestClaimsAllows(t *testing.T) {
+	// JWT users are governed by their role, not scopes
+	user := &auth.Claims{UserID: 1, TenantID: 1, Role: "customer"}
+	if !user.Allows(auth.ScopeOrdersWrite) {
+		t.Fatal("jwt claims should not be scope restricted")
+	}
+
+	key := &auth.Claims{TenantID: 1, Role: auth.RoleMerchant, KeyID: 9, Scopes: []string{auth.ScopeOrdersRead}}
+	if !key.Allows(auth.ScopeOrdersRead) {
+		t.Fatal("expected granted scope to be allowed")
+	}
+	if key.Allows(auth.ScopeOrdersWrite) {
+		t.Fatal("expected ungranted scope to be denied")
+	}
+
+	if auth.ValidScope("orders:delete") || !auth.ValidScope(auth.ScopeOrdersWrite) {
+		t.Fatal("ValidScope disagrees with auth.Scopes")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	k := models.APIKeyPrefix + "abc"
+	h := models.HashAPIKey(k)
+	if h != models.HashAPIKey(k) {
+		t.Fatal("hash must be deterministic so keys can be looked up")
+	}
+	if strings.Contains(h, "abc") || len(h) != 64 {
+		t.Fatalf("unexpected hash %q", h)
+	}
+}
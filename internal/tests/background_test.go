@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+)
+
+func TestBackgroundShutdownDrainsThenCloses(t *testing.T) {
+	bg := background.New()
+	var order []string
+	bg.OnClose("postgres", func() { order = append(order, "postgres") })
+	bg.OnClose("redis", func() { order = append(order, "redis") })
+
+	finished := make(chan struct{})
+	err := bg.Go(func(ctx context.Context) {
+		// simulate a transition in flight: finish it after stop is signalled
+		<-bg.Stopping()
+		time.Sleep(20 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Error("root context cancelled before the worker drained")
+		}
+		close(finished)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("shutdown returned before the worker finished")
+	}
+	if len(order) != 2 || order[0] != "redis" || order[1] != "postgres" {
+		t.Fatalf("closers ran in wrong order: %v", order)
+	}
+	if err := bg.Go(func(context.Context) {}); !errors.Is(err, background.ErrShuttingDown) {
+		t.Fatalf("expected ErrShuttingDown after shutdown, got %v", err)
+	}
+}
+
+func TestBackgroundShutdownDeadlineCancelsRoot(t *testing.T) {
+	bg := background.New()
+	aborted := make(chan struct{})
+	_ = bg.Go(func(ctx context.Context) {
+		<-ctx.Done() // ignores Stopping, only honours cancellation
+		close(aborted)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := bg.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("root context was not cancelled after the deadline")
+	}
+}
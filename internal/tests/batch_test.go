@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+)
+
+// bearer returns an Authorization header value for a freshly signed token, as
+// issued after two-factor authentication.
+func bearer(t *testing.T, userID, tenantID int, role string) string {
+	t.Helper()
+	auth.Configure(config.AuthConfig{JWTSecret: "test-secret", TokenTTL: time.Minute})
+	tok, err := auth.GenerateToken(userID, tenantID, role, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + tok
+}
+
+func TestBatchCreateOrdersValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	token := bearer(t, 1, 1, "customer")
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/orders/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// malformed input rejects the whole request
+	for name, tc := range map[string]struct{ ct, body, field string }{
+		"not an array":   {"application/json", `{"item":"book"}`, "body"},
+		"empty array":    {"application/json", `[]`, "body"},
+		"unknown column": {"text/csv", "item,colour\nbook,red\n", "header"},
+		"no item column": {"text/csv", "customer_id\n3\n", "header"},
+		"bad type":       {"application/xml", "<orders/>", "Content-Type"},
+	} {
+		rec := post(tc.ct, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"field":"`+tc.field+`"`) {
+			t.Fatalf("%s: expected error on %q, got %s", name, tc.field, rec.Body)
+		}
+	}
+
+	// bad rows are reported individually; none of these reach the database
+	rec := post("application/json", `[{"item":""},{"item":"book","colour":"red"},{"item":"book","customer_id":7}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Created int `json:"created"`
+		Failed  int `json:"failed"`
+		Results []struct {
+			Row    int    `json:"row"`
+			Status string `json:"status"`
+			Errors []struct {
+				Field string `json:"field"`
+			} `json:"errors"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Created != 0 || resp.Failed != 3 || len(resp.Results) != 3 {
+		t.Fatalf("unexpected report: %s", rec.Body)
+	}
+	for i, field := range []string{"item", "colour", "customer_id"} {
+		res := resp.Results[i]
+		if res.Row != i+1 || res.Status != "failed" || len(res.Errors) != 1 || res.Errors[0].Field != field {
+			t.Fatalf("row %d: expected failure on %q, got %+v", i+1, field, res)
+		}
+	}
+
+	// CSV rows with the wrong width fail on their own
+	rec = post("text/csv", "item\n\"a\",\"b\"\n")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"field":"row"`) {
+		t.Fatalf("expected per-row width error, got %d: %s", rec.Code, rec.Body)
+	}
+}
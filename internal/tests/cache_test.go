@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/cache"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/metrics"
+)
+
+func configureCache(t *testing.T, c config.CacheConfig) {
+	t.Helper()
+	cache.Configure(c)
+	t.Cleanup(func() { cache.Configure(config.CacheConfig{}) })
+}
+
+type cachedThing struct {
+	N int `json:"n"`
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	mr := useMiniredis(t)
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+	ctx := context.Background()
+
+	var loads int
+	load := func(context.Context) (*cachedThing, error) {
+		loads++
+		return &cachedThing{N: loads}, nil
+	}
+	for i := 0; i < 3; i++ {
+		v, err := cache.Fetch(ctx, "test_thing", "cache:test:1", load)
+		if err != nil || v.N != 1 {
+			t.Fatalf("fetch %d: got %+v, %v", i, v, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected one load, got %d", loads)
+	}
+	if ttl := mr.TTL("cache:test:1"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("unexpected ttl %v", ttl)
+	}
+
+	cache.Invalidate(ctx, "cache:test:1")
+	if v, _ := cache.Fetch(ctx, "test_thing", "cache:test:1", load); v.N != 2 {
+		t.Fatalf("expected a reload after invalidation, got %+v", v)
+	}
+
+	// errors are returned and not cached
+	boom := errors.New("boom")
+	_, err := cache.Fetch(ctx, "test_thing", "cache:test:2", func(context.Context) (*cachedThing, error) { return nil, boom })
+	if !errors.Is(err, boom) || mr.Exists("cache:test:2") {
+		t.Fatalf("expected uncached error, got %v", err)
+	}
+
+	// kill switch: straight to the loader, nothing written
+	configureCache(t, config.CacheConfig{Enabled: false, TTL: time.Minute})
+	if v, _ := cache.Fetch(ctx, "test_thing", "cache:test:3", load); v.N != 3 || mr.Exists("cache:test:3") {
+		t.Fatalf("disabled cache should bypass redis, got %+v", v)
+	}
+
+	// a Redis outage falls back to the loader
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+	mr.SetError("READONLY down")
+	if v, err := cache.Fetch(ctx, "test_thing", "cache:test:1", load); err != nil || v.N != 4 {
+		t.Fatalf("expected loader result during outage, got %+v, %v", v, err)
+	}
+	if err := cache.Invalidate(ctx, "cache:test:1"); err == nil {
+		t.Fatal("expected the failed invalidation reported")
+	}
+	mr.SetError("")
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`cache_requests_total{cache="test_thing",result="hit"} 2`,
+		`cache_requests_total{cache="test_thing",result="miss"} 3`,
+		`cache_requests_total{cache="test_thing",result="error"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestCacheStampedeProtection(t *testing.T) {
+	useMiniredis(t)
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (*cachedThing, error) {
+		loads.Add(1)
+		<-release
+		return &cachedThing{N: 7}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.Fetch(context.Background(), "stampede", "cache:test:hot", load)
+			if err != nil || v.N != 7 {
+				t.Errorf("got %+v, %v", v, err)
+			}
+		}()
+	}
+	// let the callers pile up on the in-flight load before it finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected concurrent misses to share one load, got %d", n)
+	}
+}
+
+func TestCacheInvalidationDuringLoad(t *testing.T) {
+	mr := useMiniredis(t)
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+	ctx := context.Background()
+
+	// the load reads the old row, then the row changes and is invalidated
+	// before the load finishes
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan *cachedThing)
+	go func() {
+		v, _ := cache.Fetch(ctx, "race", "cache:test:race", func(context.Context) (*cachedThing, error) {
+			close(started)
+			<-release
+			return &cachedThing{N: 1}, nil
+		})
+		done <- v
+	}()
+	<-started
+	cache.Invalidate(ctx, "cache:test:race")
+	close(release)
+	if v := <-done; v.N != 1 {
+		t.Fatalf("the caller still gets what was loaded, got %+v", v)
+	}
+	if mr.Exists("cache:test:race") {
+		t.Fatal("a load that raced an invalidation must not be cached")
+	}
+
+	v, err := cache.Fetch(ctx, "race", "cache:test:race", func(context.Context) (*cachedThing, error) {
+		return &cachedThing{N: 2}, nil
+	})
+	if err != nil || v.N != 2 || !mr.Exists("cache:test:race") {
+		t.Fatalf("expected the next load cached, got %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/pkg/client"
+)
+
+// clientExempt are the routes pkg/client deliberately has no method for
+var clientExempt = map[string]bool{
+	"GET /livez":        true,
+	"GET /readyz":       true,
+	"GET /metrics":      true,
+	"GET /openapi.json": true,
+}
+
+// TestClientMatchesRoutes calls every exported method of pkg/client against a stub
+// server and checks each request against the router: every request must hit a
+// route, and every route must be called by some method. The client is written by
+// hand, so this is what keeps it from drifting from the API.
+func TestClientMatchesRoutes(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []*http.Request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r.Clone(context.Background()))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL)
+	cv := reflect.ValueOf(c)
+	ctx := reflect.ValueOf(context.Background())
+	for i := 0; i < cv.NumMethod(); i++ {
+		m := cv.Type().Method(i)
+		fn := cv.Method(i)
+		args := make([]reflect.Value, fn.Type().NumIn())
+		for k := range args {
+			in := fn.Type().In(k)
+			switch {
+			case in == reflect.TypeOf((*context.Context)(nil)).Elem():
+				args[k] = ctx
+			case in == reflect.TypeOf((*io.Reader)(nil)).Elem():
+				args[k] = reflect.ValueOf(strings.NewReader(""))
+			case in.Kind() == reflect.Int:
+				args[k] = reflect.ValueOf(1)
+			default:
+				args[k] = reflect.Zero(in)
+			}
+		}
+		if m.Name == "Report" {
+			// the reports are separate routes; call each
+			for _, name := range []string{"status-daily", "delivery-time", "cancellation-rate"} {
+				args[1] = reflect.ValueOf(name)
+				fn.Call(args)
+			}
+			continue
+		}
+		for _, out := range fn.Call(args) {
+			if rc, ok := out.Interface().(io.ReadCloser); ok && rc != nil {
+				rc.Close()
+			}
+		}
+	}
+
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	called := make(map[string]bool)
+	for _, req := range reqs {
+		var match mux.RouteMatch
+		if !r.Match(req, &match) || match.MatchErr != nil {
+			t.Errorf("client calls %s %s, which no route serves", req.Method, req.URL.Path)
+			continue
+		}
+		tpl, _ := match.Route.GetPathTemplate()
+		called[req.Method+" "+tpl] = true
+	}
+	for _, op := range routerOperations(t) {
+		if !called[op] && !clientExempt[op] {
+			t.Errorf("route %q has no pkg/client method", op)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	yml := "http:\n  addr: \":9000\"\nredis:\n  addr: file:6379\norders:\n  step_delay: 2s\n"
+	if err := os.WriteFile(path, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("REDIS_ADDR", "env:6379")
+	t.Setenv("HTTP_ADDR", ":9100")
+
+	cfg, err := config.Load([]string{"-http-addr", ":9200"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Orders.StepDelay != 2*time.Second {
+		t.Errorf("file value not applied: step_delay=%v", cfg.Orders.StepDelay)
+	}
+	if cfg.Redis.Addr != "env:6379" {
+		t.Errorf("env should override file: redis.addr=%q", cfg.Redis.Addr)
+	}
+	if cfg.HTTP.Addr != ":9200" {
+		t.Errorf("flag should override env: http.addr=%q", cfg.HTTP.Addr)
+	}
+	if cfg.Postgres.MaxConns != 10 {
+		t.Errorf("default not kept: max_conns=%d", cfg.Postgres.MaxConns)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("ROUTING_MAX_ORDERS", "0")
+	_, err := config.Load(nil)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"jwt_secret must be changed", "log.format", "routing.max_orders"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	t.Setenv("ORDER_STEP_DELAY", "soon")
+	if _, err := config.Load(nil); err == nil || !strings.Contains(err.Error(), "ORDER_STEP_DELAY") {
+		t.Fatalf("expected ORDER_STEP_DELAY parse error, got %v", err)
+	}
+}
+
+func TestRetentionConfig(t *testing.T) {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Retention.InactiveAccounts != 0 {
+		t.Fatal("inactive accounts must be kept unless configured")
+	}
+	t.Setenv("RETENTION_INACTIVE_ACCOUNTS", "-24h")
+	if _, err := config.Load(nil); err == nil || !strings.Contains(err.Error(), "retention.inactive_accounts") {
+		t.Fatalf("expected a retention.inactive_accounts error, got %v", err)
+	}
+	t.Setenv("RETENTION_INACTIVE_ACCOUNTS", "0")
+	t.Setenv("RETENTION_ORDER_POSITIONS", "-1h")
+	if _, err := config.Load(nil); err == nil || !strings.Contains(err.Error(), "retention.order_positions") {
+		t.Fatalf("expected a retention.order_positions error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/eta"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/routing"
+)
+
+func TestEstimateFromHistory(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	zone, other := 1, 2
+	e := &eta.Estimator{
+		Now:        now,
+		MinSamples: 5,
+		History: &models.TransitionHistory{
+			Zones: map[int]models.Transitions{
+				zone:  {Orders: 20, Created: 10 * time.Minute, Dispatched: 5 * time.Minute, InTransit: 30 * time.Minute},
+				other: {Orders: 2, Created: time.Hour, Dispatched: time.Hour, InTransit: time.Hour},
+			},
+			All: models.Transitions{Orders: 40, Created: 20 * time.Minute, Dispatched: 10 * time.Minute, InTransit: 40 * time.Minute},
+		},
+	}
+	check := func(name string, o *models.Order, want time.Time, minConf, maxConf float64) {
+		t.Helper()
+		at, conf := e.Estimate(o)
+		if at == nil || !at.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", name, want, at)
+		}
+		if conf < minConf || conf > maxConf {
+			t.Errorf("%s: confidence %v not in [%v, %v]", name, conf, minConf, maxConf)
+		}
+	}
+
+	created := &models.Order{Status: "created", ZoneID: &zone, CreatedAt: now.Add(-5 * time.Minute)}
+	check("created", created, now.Add(40*time.Minute), 0.4, 0.6)
+	inTransitAt := now.Add(-10 * time.Minute)
+	check("in transit", &models.Order{Status: "in_transit", ZoneID: &zone, CreatedAt: now.Add(-time.Hour), InTransitAt: &inTransitAt},
+		now.Add(20*time.Minute), 0.4, 0.6)
+
+	// too few orders in zone 2, and none for orders outside a zone: the merchant's
+	// averages stand in, with less confidence
+	for name, id := range map[string]*int{"small zone": &other, "no zone": nil} {
+		o := &models.Order{Status: "created", ZoneID: id, CreatedAt: now}
+		check(name, o, now.Add(70*time.Minute), 0.1, 0.3)
+	}
+
+	// overdue orders are expected now, at half the confidence
+	late := &models.Order{Status: "created", ZoneID: &zone, CreatedAt: now.Add(-2 * time.Hour)}
+	check("overdue", late, now, 0.2, 0.3)
+
+	// nothing before the delivery window
+	after := now.Add(3 * time.Hour)
+	windowed := &models.Order{Status: "created", ZoneID: &zone, CreatedAt: now, DeliverAfter: &after}
+	check("window", windowed, after, 0.4, 0.6)
+
+	e.History.All.Orders = 3
+	if at, conf := e.Estimate(&models.Order{Status: "created", CreatedAt: now}); at != nil || conf != 0 {
+		t.Errorf("expected no estimate without enough history, got %v, %v", at, conf)
+	}
+	e.History = nil
+	if at, _ := e.Estimate(created); at != nil {
+		t.Errorf("expected no estimate without history, got %v", at)
+	}
+
+	delivered := now.Add(-time.Hour)
+	if at, conf := e.Estimate(&models.Order{Status: "delivered", DeliveredAt: &delivered}); at == nil || !at.Equal(delivered) || conf != 1 {
+		t.Errorf("expected the delivery time, got %v, %v", at, conf)
+	}
+	if at, conf := e.Estimate(&models.Order{Status: "cancelled"}); at != nil || conf != 0 {
+		t.Errorf("expected no estimate for a cancelled order, got %v, %v", at, conf)
+	}
+}
+
+func TestEstimateFromRoute(t *testing.T) {
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	courierID := 7
+	origin := geo.Point{}
+	// order 1 is dropped off 2 km east after order 2's stops 1 km east
+	planned, err := routing.Plan([]routing.Stop{
+		at(2, routing.Pickup, 1), at(2, routing.Dropoff, 1), at(1, routing.Dropoff, 2),
+	}, routing.Options{Start: &origin, At: now.Add(-time.Hour), Speed: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence(planned) != "2p 2d 1d" {
+		t.Fatalf("unexpected route %q", sequence(planned))
+	}
+	fresh := now
+	c := &models.Courier{UserID: courierID, Position: &origin, PositionAt: &fresh, Route: planned}
+	e := &eta.Estimator{Now: now, Courier: c, Options: routing.Options{Start: &origin, At: now, Speed: 10, StopTime: time.Minute}}
+	o := &models.Order{ID: 1, Status: "in_transit", CourierID: &courierID, CreatedAt: now.Add(-time.Hour)}
+
+	// 1 km, two stops of a minute, then 1 km more at 10 m/s
+	got, conf := e.Estimate(o)
+	if want := now.Add(320 * time.Second); got == nil || got.Sub(want).Abs() > time.Second {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if conf < 0.75 || conf > 0.9 {
+		t.Errorf("unexpected confidence %v", conf)
+	}
+
+	// a stale position is trusted less, and a route without one less still
+	stale := now.Add(-30 * time.Minute)
+	c.PositionAt = &stale
+	if _, staleConf := e.Estimate(o); staleConf >= conf/2 {
+		t.Errorf("expected a stale position to lower confidence, got %v", staleConf)
+	}
+	c.Position, c.PositionAt, e.Options.Start = nil, nil, nil
+	got, _ = e.Estimate(o)
+	if got == nil || !got.Equal(now) {
+		t.Errorf("expected the overdue planned arrival to be clamped to now, got %v", got)
+	}
+
+	// an order that isn't this courier's has no route estimate and no history
+	other := 8
+	if got, _ := e.Estimate(&models.Order{ID: 1, Status: "in_transit", CourierID: &other}); got != nil {
+		t.Errorf("expected no estimate, got %v", got)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	tr := models.Transitions{Created: time.Minute, Dispatched: 2 * time.Minute, InTransit: 3 * time.Minute}
+	for status, want := range map[string]time.Duration{"created": 6 * time.Minute, "dispatched": 5 * time.Minute, "in_transit": 3 * time.Minute} {
+		if got, ok := tr.Remaining(status); !ok || got != want {
+			t.Errorf("%s: expected %v, got %v", status, want, got)
+		}
+	}
+	if _, ok := tr.Remaining("delivered"); ok {
+		t.Error("nothing remains after delivery")
+	}
+
+	zone := 3
+	h := &models.TransitionHistory{Zones: map[int]models.Transitions{zone: tr}}
+	if h.Zone(&zone) != tr || h.Zone(nil).Orders != 0 {
+		t.Error("unexpected zone lookup")
+	}
+}
+
+func TestETAConfig(t *testing.T) {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ETA.History <= 0 || cfg.ETA.MinSamples <= 0 {
+		t.Fatalf("unexpected defaults %+v", cfg.ETA)
+	}
+	t.Setenv("ETA_MIN_SAMPLES", "0")
+	if _, err := config.Load(nil); err == nil || !strings.Contains(err.Error(), "eta.min_samples") {
+		t.Fatalf("expected an eta.min_samples error, got %v", err)
+	}
+}
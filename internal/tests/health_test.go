@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/health"
+	"github.com/rajnish-012/delivery-management-system/internal/orders"
+)
+
+// resetHealth clears the registered checks and shutdown flag before and after t
+func resetHealth(t *testing.T) {
+	t.Helper()
+	health.Reset()
+	t.Cleanup(health.Reset)
+}
+
+func TestReadinessChecks(t *testing.T) {
+	resetHealth(t)
+	health.Register(health.Check{Name: "fast", Fn: func(context.Context) error { return nil }})
+	health.Register(health.Check{Name: "broken", Fn: func(context.Context) error { return errors.New("down") }})
+	health.Register(health.Check{Name: "slow", Timeout: 20 * time.Millisecond, Fn: func(ctx context.Context) error {
+		time.Sleep(time.Second) // ignores ctx on purpose
+		return nil
+	}})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	health.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("readiness waited on a check past its timeout")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var rep health.Report
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Checks["fast"].Status != "ok" {
+		t.Errorf("fast: %+v", rep.Checks["fast"])
+	}
+	if rep.Checks["broken"].Error != "down" {
+		t.Errorf("broken: %+v", rep.Checks["broken"])
+	}
+	if rep.Checks["slow"].Status != "fail" {
+		t.Errorf("slow should time out: %+v", rep.Checks["slow"])
+	}
+
+	health.SetShuttingDown()
+	rec = httptest.NewRecorder()
+	health.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("expected 503 while shutting down, got %d", rec.Code)
+	}
+
+	// liveness is unaffected by dependency state
+	rec = httptest.NewRecorder()
+	health.LiveHandler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected livez 200, got %d", rec.Code)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	orders.ConfigureHeartbeat(10 * time.Millisecond)
+	if age := orders.HeartbeatAge(); age != 0 {
+		t.Fatalf("expected no age with no loop running, got %v", age)
+	}
+
+	// no batch slots: the feeder waits for one, beating as it does
+	bg := background.New()
+	cfg := config.Default().Orders
+	cfg.BatchConcurrency = 0
+	orders.Configure(cfg, bg)
+	t.Cleanup(func() {
+		_ = bg.Shutdown(context.Background())
+		orders.Configure(config.Default().Orders, background.New())
+	})
+	if err := orders.QueueProgressions(context.Background(), []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if age := orders.HeartbeatAge(); age <= 0 || age > 50*time.Millisecond {
+		t.Fatalf("expected the waiting feeder to keep beating, got %v", age)
+	}
+
+	if err := bg.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if age := orders.HeartbeatAge(); age != 0 {
+		t.Fatalf("expected no age once the feeder stopped, got %v", age)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rajnish-012/delivery-management-system/internal/lock"
+)
+
+func TestLeaseLock(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	const ttl = 300 * time.Millisecond
+
+	first, err := lock.Acquire(ctx, "lock:order:1", ttl)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := lock.Acquire(ctx, "lock:order:1", ttl); !errors.Is(err, lock.ErrNotAcquired) {
+		t.Fatalf("second acquire: expected ErrNotAcquired, got %v", err)
+	}
+	other, err := lock.Acquire(ctx, "lock:order:2", ttl)
+	if err != nil {
+		t.Fatalf("acquire other key: %v", err)
+	}
+	_ = other.Release(ctx)
+
+	// renewal keeps the lease alive past its TTL
+	mr.FastForward(ttl / 2)
+	time.Sleep(ttl / 2)
+	if got := mr.TTL("lock:order:1"); got != ttl {
+		t.Fatalf("expected renewal to reset the ttl to %v, got %v", ttl, got)
+	}
+
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if mr.Exists("lock:order:1") {
+		t.Fatal("release should delete the lease")
+	}
+	second, err := lock.Acquire(ctx, "lock:order:1", ttl)
+	if err != nil {
+		t.Fatalf("reacquire: %v", err)
+	}
+	if second.Token() <= first.Token() {
+		t.Fatalf("fencing tokens must increase: %d then %d", first.Token(), second.Token())
+	}
+
+	// the lease lapses (e.g. a long pause) and another owner takes it: the old
+	// holder notices on its next renewal, and releasing leaves the new lease alone
+	mr.FastForward(ttl)
+	third, err := lock.Acquire(ctx, "lock:order:1", ttl)
+	if err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	select {
+	case <-second.Lost():
+	case <-time.After(ttl):
+		t.Fatal("expected the expired lease to report Lost")
+	}
+	if err := second.Release(ctx); err != nil {
+		t.Fatalf("release lost lease: %v", err)
+	}
+	if !mr.Exists("lock:order:1") {
+		t.Fatal("releasing a lost lease must not delete the new owner's lease")
+	}
+	if third.Token() <= second.Token() {
+		t.Fatalf("fencing tokens must increase: %d then %d", second.Token(), third.Token())
+	}
+	_ = third.Release(ctx)
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/logging"
+)
+
+func TestLoggingMiddlewareRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := logging.Init(&buf, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	h := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set(logging.RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get(logging.RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected incoming request id to be echoed, got %q", got)
+	}
+
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		if line["request_id"] != "abc-123" {
+			t.Fatalf("log line without request_id: %v", line)
+		}
+		if line["msg"] == "http request" {
+			seen = "access"
+			if line["status"] != float64(http.StatusTeapot) {
+				t.Fatalf("access log has wrong status: %v", line["status"])
+			}
+		}
+	}
+	if seen != "access" {
+		t.Fatal("no access log written")
+	}
+
+	// a missing header gets a generated id
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if rec.Header().Get(logging.RequestIDHeader) == "" {
+		t.Fatal("expected a generated request id")
+	}
+}
+
+func TestInternalErrorHidesDetail(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := logging.Init(&buf, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+	// without Redis the login challenge can't be read, which is a 500
+	prev := database.Rdb
+	database.Rdb = nil
+	t.Cleanup(func() { database.Rdb = prev })
+
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"challenge":"abc","code":"123456"}`))
+	req.Header.Set(logging.RequestIDHeader, "req-500")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "internal server error" {
+		t.Errorf("expected a generic message, got %q", body)
+	}
+	if !strings.Contains(buf.String(), "redis not initialized") || !strings.Contains(buf.String(), "req-500") {
+		t.Errorf("expected the error in the log with the request id, got %s", buf.String())
+	}
+}
@@ -2,38 +2,51 @@ package tests
 
 import (
     "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
     "testing"
+    "time"
+
+    "github.com/jackc/pgx/v5"
     "github.com/rajnish-012/delivery-management-system/internal/auth"
+    "github.com/rajnish-012/delivery-management-system/internal/config"
     "github.com/rajnish-012/delivery-management-system/internal/database"
     "github.com/rajnish-012/delivery-management-system/internal/models"
 )
 
 func TestUserAndOrderLifecycle(t *testing.T) {
-    ctx := context.Background()
-    if err := database.InitPostgres(ctx); err != nil {
+    ctx := database.WithTenant(context.Background(), models.OperatorTenantID)
+    cfg, err := config.Load(nil)
+    if err != nil {
+        t.Fatalf("config: %v", err)
+    }
+    auth.Configure(cfg.Auth)
+    if err := database.InitPostgres(ctx, cfg.Postgres); err != nil {
         t.Fatalf("pg init: %v", err)
     }
     defer database.ClosePostgres()
-    if err := database.InitRedis(ctx); err != nil {
+    if err := database.InitRedis(ctx, cfg.Redis); err != nil {
         t.Fatalf("redis init: %v", err)
     }
 
     // cleanup tables (safe for tests)
     database.Pool.Exec(ctx, "TRUNCATE orders, users RESTART IDENTITY CASCADE")
+    database.Pool.Exec(ctx, "DELETE FROM merchants WHERE id <> $1", models.OperatorTenantID)
 
     // create user
-    u, err := models.CreateUser(ctx, "testuser", "pass123", "customer")
+    u, err := models.CreateUser(ctx, "testuser", "pass123", "customer", models.Profile{Email: "testuser@example.com"})
     if err != nil {
         t.Fatalf("create user: %v", err)
     }
 
     // generate token (basic sanity)
-    if _, err := auth.GenerateToken(u.ID, u.Role); err != nil {
+    if _, err := auth.GenerateToken(u.ID, u.TenantID, u.Role, false); err != nil {
         t.Fatalf("jwt: %v", err)
     }
 
     // create order
-    ord, err := models.CreateOrder(ctx, u.ID, "book")
+    ord, err := models.CreateOrder(ctx, models.NewOrder{CustomerID: u.ID, Item: "book"})
     if err != nil {
         t.Fatalf("create order: %v", err)
     }
@@ -45,8 +58,12 @@ func TestUserAndOrderLifecycle(t *testing.T) {
     // (use orders.StartProgression)
     // to avoid import cycle, run minimal check: Update status then verify Cancel works
 
-    if err := models.UpdateOrderStatus(ctx, ord.ID, "dispatched"); err != nil {
-        t.Fatalf("update: %v", err)
+    if upd, err := models.UpdateOrderStatus(ctx, ord.ID, "created", "dispatched", 0); err != nil || upd == nil {
+        t.Fatalf("update: %v %v", upd, err)
+    }
+    // compare-and-set: a write expecting the old status is refused
+    if upd, err := models.UpdateOrderStatus(ctx, ord.ID, "created", "in_transit", 0); err != nil || upd != nil {
+        t.Fatalf("stale update: expected no change, got %v %v", upd, err)
     }
 
     o2, err := models.GetOrderByID(ctx, ord.ID)
@@ -57,14 +74,76 @@ func TestUserAndOrderLifecycle(t *testing.T) {
         t.Fatalf("expected dispatched, got %s", o2.Status)
     }
 
+    // another merchant can't see the order
+    other, err := models.CreateMerchant(ctx, "other", "Other merchant")
+    if err != nil {
+        t.Fatalf("create merchant: %v", err)
+    }
+    otherCtx := database.WithTenant(context.Background(), other.ID)
+    if _, err := models.GetOrderByID(otherCtx, ord.ID); err == nil {
+        t.Fatalf("order %d visible to tenant %d", ord.ID, other.ID)
+    }
+    if list, _ := models.ListAllOrders(otherCtx); len(list) != 0 {
+        t.Fatalf("expected no orders for tenant %d, got %d", other.ID, len(list))
+    }
+
     // cancel
-    if err := models.CancelOrder(ctx, ord.ID); err != nil {
-        t.Fatalf("cancel: %v", err)
+    if _, from, err := models.CancelOrder(ctx, ord.ID); err != nil || from != "dispatched" {
+        t.Fatalf("cancel: from=%q %v", from, err)
     }
     o3, _ := models.GetOrderByID(ctx, ord.ID)
     if o3.Status != "cancelled" {
         t.Fatalf("expected cancelled got %s", o3.Status)
     }
+    // progression can't overwrite a cancel
+    if upd, _ := models.UpdateOrderStatus(ctx, ord.ID, "dispatched", "in_transit", 0); upd != nil {
+        t.Fatalf("expected the cancel to win, order is %s", upd.Status)
+    }
+    if _, from, _ := models.CancelOrder(ctx, ord.ID); from != "" {
+        t.Fatalf("second cancel should change nothing, cancelled from %q", from)
+    }
+
+    // API keys: a live key authenticates and records its use; expired, revoked and
+    // unknown keys are refused
+    protected := auth.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    useKey := func(key string) int {
+        req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+        req.Header.Set(auth.APIKeyHeader, key)
+        rec := httptest.NewRecorder()
+        protected.ServeHTTP(rec, req)
+        return rec.Code
+    }
+    live, k, err := models.CreateAPIKey(ctx, "live", []string{auth.ScopeOrdersRead}, nil, u.ID)
+    if err != nil {
+        t.Fatalf("create api key: %v", err)
+    }
+    if code := useKey(live); code != http.StatusOK {
+        t.Fatalf("live key: expected 200, got %d", code)
+    }
+    keys, err := models.ListAPIKeys(ctx)
+    if err != nil || len(keys) != 1 || keys[0].LastUsedAt == nil {
+        t.Fatalf("expected the key's use recorded, got %v %v", keys, err)
+    }
+    past := time.Now().Add(-time.Minute)
+    expired, _, err := models.CreateAPIKey(ctx, "expired", []string{auth.ScopeOrdersRead}, &past, u.ID)
+    if err != nil {
+        t.Fatalf("create expired api key: %v", err)
+    }
+    if code := useKey(expired); code != http.StatusUnauthorized {
+        t.Fatalf("expired key: expected 401, got %d", code)
+    }
+    if _, err := models.RevokeAPIKey(otherCtx, k.ID); !errors.Is(err, pgx.ErrNoRows) {
+        t.Fatalf("another merchant revoked the key: %v", err)
+    }
+    if revoked, err := models.RevokeAPIKey(ctx, k.ID); err != nil || revoked.RevokedAt == nil {
+        t.Fatalf("revoke api key: %v %v", revoked, err)
+    }
+    if code := useKey(live); code != http.StatusUnauthorized {
+        t.Fatalf("revoked key: expected 401, got %d", code)
+    }
+    if code := useKey(models.APIKeyPrefix + "unknown"); code != http.StatusUnauthorized {
+        t.Fatalf("unknown key: expected 401, got %d", code)
+    }
 
     // done
 }
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+)
+
+func TestMetricsLabelRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+
+	// unauthenticated, so the request stops at AuthMiddleware without touching the database
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/orders/42/cancel", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	want := `http_request_duration_seconds_count{code="401",method="POST",route="/api/orders/{id}/cancel"} 1`
+	if !strings.Contains(body, want) {
+		t.Fatalf("metrics output missing %q", want)
+	}
+	for _, name := range []string{"orders_progressions_active", "http_requests_in_flight"} {
+		if !strings.Contains(body, name) {
+			t.Fatalf("metrics output missing %s", name)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+)
+
+// recorder is a Notifier that keeps what it was asked to send
+type recorder struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (r *recorder) Send(ctx context.Context, m notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func (r *recorder) messages() []notify.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]notify.Message(nil), r.sent...)
+}
+
+func useRecorder(t *testing.T, channel string) *recorder {
+	t.Helper()
+	rec := &recorder{}
+	notify.SetNotifier(channel, rec)
+	t.Cleanup(func() { notify.SetNotifier(channel, nil) })
+	return rec
+}
+
+func TestRenderTemplates(t *testing.T) {
+	d := notify.Data{Name: "alice", OrderID: 42, Item: "book"}
+	for _, locale := range notify.Locales() {
+		for _, status := range []string{"dispatched", "in_transit", "delivered", "cancelled"} {
+			subject, body, ok, err := notify.Render(locale, status, notify.ChannelEmail, d)
+			if err != nil || !ok || !strings.Contains(subject, "#42") || !strings.Contains(body, "alice") || !strings.Contains(body, "book") {
+				t.Errorf("%s/%s email: %q %q ok=%v err=%v", locale, status, subject, body, ok, err)
+			}
+			subject, body, ok, err = notify.Render(locale, status, notify.ChannelSMS, d)
+			if err != nil || !ok || subject != "" || !strings.Contains(body, "#42") || len(body) > 160 {
+				t.Errorf("%s/%s sms: %q %q ok=%v err=%v", locale, status, subject, body, ok, err)
+			}
+		}
+	}
+	if len(notify.Locales()) < 2 {
+		t.Fatalf("expected several locales, got %v", notify.Locales())
+	}
+
+	de, _, _, _ := notify.Render("de", "delivered", notify.ChannelEmail, d)
+	fallback, _, _, _ := notify.Render("xx", "delivered", notify.ChannelEmail, d)
+	en, _, _, _ := notify.Render("en", "delivered", notify.ChannelEmail, d)
+	if de == en || fallback != en {
+		t.Fatalf("expected a German subject and an English fallback, got %q, %q, %q", de, fallback, en)
+	}
+	if _, _, ok, _ := notify.Render("en", "created", notify.ChannelEmail, d); ok {
+		t.Fatal("created should not notify")
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	night := &models.NotificationPrefs{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Europe/Berlin"}
+	lunch := &models.NotificationPrefs{QuietStart: "12:00", QuietEnd: "13:30", Timezone: "Europe/Berlin"}
+	at := func(day, h, m int) time.Time { return time.Date(2024, 3, day, h, m, 0, 0, berlin) }
+
+	for _, tc := range []struct {
+		name  string
+		prefs *models.NotificationPrefs
+		now   time.Time
+		until time.Time
+		quiet bool
+	}{
+		{"late evening", night, at(5, 23, 30), at(6, 7, 0), true},
+		{"early morning", night, at(6, 6, 59), at(6, 7, 0), true},
+		{"end is exclusive", night, at(6, 7, 0), time.Time{}, false},
+		{"daytime", night, at(6, 15, 0), time.Time{}, false},
+		{"in lunch break", lunch, at(6, 12, 15), at(6, 13, 30), true},
+		{"after lunch break", lunch, at(6, 13, 30), time.Time{}, false},
+		// same instant seen from UTC: 22:30 UTC is 23:30 in Berlin
+		{"other zone", night, time.Date(2024, 3, 5, 22, 30, 0, 0, time.UTC), at(6, 7, 0), true},
+		{"no quiet hours", &models.NotificationPrefs{Timezone: "UTC"}, at(6, 3, 0), time.Time{}, false},
+	} {
+		until, quiet := notify.QuietUntil(tc.now, tc.prefs)
+		if quiet != tc.quiet || !until.Equal(tc.until) {
+			t.Errorf("%s: got %v %v, want %v %v", tc.name, until, quiet, tc.until, tc.quiet)
+		}
+	}
+}
+
+func TestComposeAndDeliver(t *testing.T) {
+	mr := useMiniredis(t)
+	email := useRecorder(t, notify.ChannelEmail)
+	sms := useRecorder(t, notify.ChannelSMS)
+	ctx := context.Background()
+
+	u := &models.User{ID: 7, Username: "alice", Profile: models.Profile{Email: "alice@example.com"}}
+	o := &models.Order{ID: 42, CustomerID: 7, Item: "book"}
+	prefs := &models.NotificationPrefs{UserID: 7, EmailEnabled: true, SMSEnabled: true, Locale: "es",
+		QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"}
+
+	// SMS is enabled but there is no phone number to send to
+	msgs := notify.Compose(ctx, u, prefs, o, "delivered")
+	if len(msgs) != 1 || msgs[0].Channel != notify.ChannelEmail || msgs[0].To != "alice@example.com" || !strings.Contains(msgs[0].Subject, "pedido") {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+	u.Phone = "+4915112345678"
+	msgs = notify.Compose(ctx, u, prefs, o, "delivered")
+	if len(msgs) != 2 || msgs[1].Channel != notify.ChannelSMS || msgs[1].To != u.Phone {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	// outside quiet hours messages go straight out
+	noon := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	notify.Deliver(ctx, msgs[0], prefs, noon)
+	if got := email.messages(); len(got) != 1 {
+		t.Fatalf("expected one email sent, got %+v", got)
+	}
+
+	// during quiet hours they are held until the end
+	night := time.Date(2024, 3, 6, 23, 0, 0, 0, time.UTC)
+	notify.Deliver(ctx, msgs[1], prefs, night)
+	if len(sms.messages()) != 0 {
+		t.Fatal("sms sent during quiet hours")
+	}
+	if held, _ := mr.ZMembers("notify:deferred"); len(held) != 1 {
+		t.Fatalf("expected one held message, got %d", len(held))
+	}
+	notify.ReleaseDue(ctx, night.Add(time.Hour))
+	if len(sms.messages()) != 0 {
+		t.Fatal("held sms released before quiet hours ended")
+	}
+	morning := time.Date(2024, 3, 7, 7, 0, 0, 0, time.UTC)
+	notify.ReleaseDue(ctx, morning)
+	notify.ReleaseDue(ctx, morning)
+	if got := sms.messages(); len(got) != 1 || got[0].To != u.Phone || got[0].OrderID != 42 {
+		t.Fatalf("expected the held sms sent once, got %+v", got)
+	}
+	if mr.Exists("notify:deferred") {
+		t.Fatal("released message still held")
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	n := notify.NewFileNotifier(path)
+	for _, to := range []string{"a@example.com", "+4915112345678"} {
+		if err := n.Send(context.Background(), notify.Message{Channel: "email", To: to, Body: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []notify.Message
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m notify.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	if len(lines) != 2 || lines[1].To != "+4915112345678" {
+		t.Fatalf("unexpected file contents: %+v", lines)
+	}
+}
+
+func TestSMSProviderNotifier(t *testing.T) {
+	var got map[string]string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sms-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := notify.NewSMSProviderNotifier(config.SMSProviderConfig{URL: srv.URL, Token: "sms-token", From: "DMS", Timeout: time.Second})
+	m := notify.Message{Channel: notify.ChannelSMS, To: "+4915112345678", Body: "Order #1 has shipped."}
+	if err := n.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if got["from"] != "DMS" || got["to"] != m.To || got["body"] != m.Body {
+		t.Fatalf("unexpected payload: %v", got)
+	}
+	status = http.StatusBadGateway
+	if err := n.Send(context.Background(), m); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected a provider error, got %v", err)
+	}
+}
+
+// fakeSMTP serves one SMTP session per connection and sends what it was given as
+// the message data on got. A silent server accepts connections and says nothing.
+func fakeSMTP(t *testing.T, silent bool) (addr string, got <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	data := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if silent {
+				t.Cleanup(func() { conn.Close() })
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+				reply("220 localhost ESMTP")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						reply("250 localhost")
+					case cmd == "DATA":
+						reply("354 go ahead")
+						var b strings.Builder
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+							b.WriteString(l)
+						}
+						data <- b.String()
+						reply("250 queued")
+					case cmd == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	m := notify.Message{Channel: notify.ChannelEmail, To: "alice@example.com", Subject: "Order #1", Body: "It has shipped."}
+	addr, got := fakeSMTP(t, false)
+	n := notify.NewSMTPNotifier(config.SMTPConfig{Addr: addr, From: "orders@example.com", Timeout: time.Second})
+	if err := n.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-got; !strings.Contains(data, "To: alice@example.com") || !strings.Contains(data, "It has shipped.") {
+		t.Fatalf("unexpected message %q", data)
+	}
+
+	// a server that never answers fails the send after the timeout
+	addr, _ = fakeSMTP(t, true)
+	n = notify.NewSMTPNotifier(config.SMTPConfig{Addr: addr, From: "orders@example.com", Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	if err := n.Send(context.Background(), m); err == nil || time.Since(start) > time.Second {
+		t.Fatalf("expected a timeout, got %v after %v", err, time.Since(start))
+	}
+
+	// and cancelling the context ends it sooner
+	n = notify.NewSMTPNotifier(config.SMTPConfig{Addr: addr, From: "orders@example.com", Timeout: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	if err := n.Send(ctx, m); err == nil || time.Since(start) > time.Second {
+		t.Fatalf("expected the send cancelled, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestNotificationPrefsValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	token := bearer(t, 1, 1, "customer")
+
+	for name, tc := range map[string]struct{ body, field string }{
+		"unknown locale":    {`{"locale":"xx","timezone":"UTC"}`, "locale"},
+		"unknown zone":      {`{"locale":"en","timezone":"Mars/Olympus"}`, "timezone"},
+		"half quiet hours":  {`{"locale":"en","timezone":"UTC","quiet_start":"22:00"}`, "quiet_end"},
+		"bad clock":         {`{"locale":"en","timezone":"UTC","quiet_start":"25:00","quiet_end":"07:00"}`, "quiet_start"},
+		"empty quiet hours": {`{"locale":"en","timezone":"UTC","quiet_start":"07:00","quiet_end":"07:00"}`, "quiet_end"},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/api/me/notifications", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"field":"`+tc.field+`"`) {
+			t.Fatalf("%s: expected error on %q, got %s", name, tc.field, rec.Body)
+		}
+	}
+}
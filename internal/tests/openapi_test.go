@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+)
+
+type openapiDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]json.RawMessage `json:"components"`
+}
+
+func loadSpec(t *testing.T) (*openapiDoc, []byte) {
+	t.Helper()
+	var doc openapiDoc
+	if err := json.Unmarshal(api.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return &doc, api.OpenAPISpec
+}
+
+// routerOperations returns "METHOD /path" for every route registered by api.RegisterRoutes.
+func routerOperations(t *testing.T) []string {
+	t.Helper()
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	var ops []string
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// subrouter prefixes carry no methods
+			return nil
+		}
+		for _, m := range methods {
+			ops = append(ops, m+" "+tpl)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	doc, _ := loadSpec(t)
+	var specOps []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			specOps = append(specOps, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(specOps)
+
+	inSpec := make(map[string]bool, len(specOps))
+	for _, op := range specOps {
+		inSpec[op] = true
+	}
+	routed := make(map[string]bool)
+	for _, op := range routerOperations(t) {
+		routed[op] = true
+		if !inSpec[op] {
+			t.Errorf("route %q is registered but missing from openapi.json", op)
+		}
+	}
+	for _, op := range specOps {
+		if !routed[op] {
+			t.Errorf("openapi.json documents %q but no such route is registered", op)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	doc, raw := loadSpec(t)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := x["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				if len(parts) != 2 || doc.Components[parts[0]][parts[1]] == nil {
+					t.Errorf("unresolved $ref %q", ref)
+				}
+			}
+			for _, child := range x {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range x {
+				walk(child)
+			}
+		}
+	}
+	var tree interface{}
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		t.Fatal(err)
+	}
+	walk(tree)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if rec.Body.Len() != len(api.OpenAPISpec) {
+		t.Fatalf("served document differs from embedded spec")
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+)
+
+func TestDeleteAccountValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	call := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	customer := bearer(t, 2, 1, "customer")
+	admin := bearer(t, 1, 1, "admin")
+
+	rec := call(customer, http.MethodDelete, "/api/me", `{}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"password"`) {
+		t.Fatalf("expected 400 on password, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(admin, http.MethodDelete, "/api/admin/users/1", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an admin deleting themselves, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, path := range []string{"/api/admin/users/3", "/api/admin/users/3/export"} {
+		method := http.MethodGet
+		if !strings.HasSuffix(path, "/export") {
+			method = http.MethodDelete
+		}
+		if rec := call(customer, method, path, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403 for a customer, got %d", method, path, rec.Code)
+		}
+	}
+}
+
+func TestDeletedUsernameCantRegister(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	body := `{"username":"` + models.DeletedUsername(7) + `","password":"Secret123","role":"customer"}`
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"username"`) {
+		t.Fatalf("expected the deleted-user name rejected, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestForgetHeldMessages(t *testing.T) {
+	mr := useMiniredis(t)
+	sms := useRecorder(t, notify.ChannelSMS)
+	ctx := context.Background()
+	prefs := &models.NotificationPrefs{SMSEnabled: true, QuietStart: "22:00", QuietEnd: "07:00", Timezone: "UTC"}
+	night := time.Date(2024, 3, 6, 23, 0, 0, 0, time.UTC)
+	for i, to := range []string{"+4915100000001", "+4915100000002", "+4915100000001"} {
+		notify.Deliver(ctx, notify.Message{Channel: notify.ChannelSMS, To: to, Body: "on its way", OrderID: i + 1}, prefs, night)
+	}
+
+	n, err := notify.Forget(ctx, "", "+4915100000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 messages forgotten, got %d", n)
+	}
+	if held, _ := mr.ZMembers("notify:deferred"); len(held) != 1 {
+		t.Fatalf("expected one held message left, got %d", len(held))
+	}
+	notify.ReleaseDue(ctx, night.Add(12*time.Hour))
+	if got := sms.messages(); len(got) != 1 || got[0].To != "+4915100000002" {
+		t.Fatalf("expected only the other recipient's message sent, got %+v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/background"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/notify"
+)
+
+func TestProfileValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	token := bearer(t, 1, 1, "customer")
+
+	for name, tc := range map[string]struct{ method, path, body, field string }{
+		"bad email":        {http.MethodPut, "/api/me", `{"email":"not-an-address"}`, "email"},
+		"bad phone":        {http.MethodPut, "/api/me", `{"phone":"0151 1234"}`, "phone"},
+		"long name":        {http.MethodPut, "/api/me", `{"display_name":"` + strings.Repeat("a", 101) + `"}`, "display_name"},
+		"weak password":    {http.MethodPost, "/api/me/password", `{"current_password":"secret123","new_password":"password"}`, "new_password"},
+		"missing current":  {http.MethodPost, "/api/me/password", `{"new_password":"secret123"}`, "current_password"},
+		"missing username": {http.MethodPost, "/password-reset", `{}`, "username"},
+		"missing token":    {http.MethodPost, "/password-reset/confirm", `{"new_password":"secret123"}`, "token"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"field":"`+tc.field+`"`) {
+			t.Fatalf("%s: expected error on %q, got %s", name, tc.field, rec.Body)
+		}
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	mr := useMiniredis(t)
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+	unreachablePostgres(t)
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	old := bearer(t, 5, 1, "customer")
+	other := bearer(t, 6, 1, "customer")
+	mr.Set("cache:session:1:6", `{"disabled":false,"revoked_at":null}`)
+
+	call := func(token string) *httptest.ResponseRecorder {
+		// an invalid body gets a 400 from the handler without touching the database
+		req := httptest.NewRequest(http.MethodPut, "/api/me", strings.NewReader(`{"email":"x"}`))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	revokedAt := func(at time.Time) {
+		b, err := json.Marshal(models.Session{RevokedAt: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr.Set("cache:session:1:5", string(b))
+	}
+
+	revokedAt(time.Now().Add(-time.Hour))
+	if rec := call(old); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the token accepted before revocation, got %d: %s", rec.Code, rec.Body)
+	}
+	// issue times are compared to the microsecond, so a revocation within the same
+	// second as a token still voids it
+	time.Sleep(time.Millisecond)
+	revokedAt(time.Now())
+	if rec := call(old); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "revoked") {
+		t.Fatalf("expected the old token rejected, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(other); rec.Code != http.StatusBadRequest {
+		t.Fatalf("another user's token should be unaffected, got %d: %s", rec.Code, rec.Body)
+	}
+	time.Sleep(time.Millisecond)
+	if rec := call(bearer(t, 5, 1, "customer")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("a token issued after revocation should work, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// revoking needs Postgres; the cached session must not be touched if it fails
+	if err := auth.RevokeSessions(database.WithTenant(context.Background(), 1), 5); err == nil {
+		t.Fatal("expected revocation to fail without Postgres")
+	}
+	if _, err := mr.Get("cache:session:1:5"); err != nil {
+		t.Fatal("the cached session should survive a failed revocation")
+	}
+}
+
+func TestPasswordResetAnswersBeforeLookup(t *testing.T) {
+	// a database that accepts connections and never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+	pool, err := pgxpool.New(context.Background(), "postgres://u@"+ln.Addr().String()+"/none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := database.Pool
+	database.Pool = pool
+	t.Cleanup(func() {
+		database.Pool = prev
+		pool.Close()
+	})
+
+	bg := background.New()
+	if err := auth.StartPasswordResets(bg); err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	req := httptest.NewRequest(http.MethodPost, "/password-reset", strings.NewReader(`{"username":"alice"}`))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted || time.Since(start) > time.Second {
+		t.Fatalf("expected 202 right away, got %d after %v", rec.Code, time.Since(start))
+	}
+	select {
+	case c := <-accepted:
+		defer c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker never looked the user up")
+	}
+
+	// the stuck lookup is cut off by the drain deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	bg.Shutdown(ctx)
+}
+
+func TestPasswordResetMessage(t *testing.T) {
+	auth.Configure(config.AuthConfig{JWTSecret: "test-secret", TokenTTL: time.Minute, ResetURL: "https://shop.example.com/reset?lang=de"})
+	t.Cleanup(func() { auth.Configure(config.AuthConfig{JWTSecret: "test-secret", TokenTTL: time.Minute}) })
+	token := strings.Repeat("A", 43) // 32 random bytes, base64url
+	link := auth.ResetLink(token)
+	if link != "https://shop.example.com/reset?lang=de&token="+token {
+		t.Fatalf("unexpected link %q", link)
+	}
+
+	for _, locale := range notify.Locales() {
+		subject, body, ok, err := notify.Render(locale, notify.PasswordResetTemplate, notify.ChannelEmail, notify.Data{Name: "Alice", Link: link})
+		if err != nil || !ok || subject == "" || !strings.Contains(body, link) || !strings.Contains(body, "Alice") {
+			t.Errorf("%s email: %q %q ok=%v err=%v", locale, subject, body, ok, err)
+		}
+		_, body, ok, err = notify.Render(locale, notify.PasswordResetTemplate, notify.ChannelSMS, notify.Data{Link: token})
+		if err != nil || !ok || !strings.Contains(body, token) || len(body) > 160 {
+			t.Errorf("%s sms: %q ok=%v err=%v", locale, body, ok, err)
+		}
+	}
+
+	email := useRecorder(t, notify.ChannelEmail)
+	sms := useRecorder(t, notify.ChannelSMS)
+	u := &models.User{ID: 7, Username: "alice", Profile: models.Profile{DisplayName: "Alice", Phone: "+4915112345678"}}
+	// no email: the token goes by SMS
+	if err := notify.PasswordReset(context.Background(), u, "en", token); err != nil {
+		t.Fatal(err)
+	}
+	u.Email = "alice@example.com"
+	if err := notify.PasswordReset(context.Background(), u, "de", link); err != nil {
+		t.Fatal(err)
+	}
+	if got := sms.messages(); len(got) != 1 || got[0].To != u.Phone || !strings.Contains(got[0].Body, token) {
+		t.Fatalf("expected one sms with the token, got %+v", got)
+	}
+	if got := email.messages(); len(got) != 1 || got[0].To != u.Email || !strings.Contains(got[0].Body, "Hallo Alice") {
+		t.Fatalf("expected one German email with the link, got %+v", got)
+	}
+	if err := notify.PasswordReset(context.Background(), &models.User{ID: 8}, "en", token); err == nil {
+		t.Fatal("expected an error for a user without email or phone")
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+	"github.com/rajnish-012/delivery-management-system/internal/ratelimit"
+)
+
+// useMiniredis points database.Rdb at an in-memory Redis for the duration of the test.
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	prev := database.Rdb
+	database.Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.Rdb.Close()
+		database.Rdb = prev
+	})
+	return mr
+}
+
+func configureLimits(t *testing.T, c config.LimitsConfig) {
+	t.Helper()
+	ratelimit.Configure(c)
+	t.Cleanup(func() { ratelimit.Configure(config.LimitsConfig{}) })
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	useMiniredis(t)
+	configureLimits(t, config.LimitsConfig{
+		Enabled: true,
+		Groups: map[string]map[string]config.Rule{
+			"auth": {"ip": {Requests: 3, Window: time.Minute}},
+		},
+	})
+
+	h := ratelimit.Middleware("auth", ratelimit.IP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := do("10.0.0.1:1234")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: expected 204, got %d", i+1, rec.Code)
+		}
+		if got, want := rec.Header().Get("X-RateLimit-Remaining"), []string{"2", "1", "0"}[i]; got != want {
+			t.Fatalf("request %d: remaining %q, want %q", i+1, got, want)
+		}
+	}
+	rec := do("10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("X-RateLimit-Limit") != "3" {
+		t.Fatalf("missing rate limit headers: %v", rec.Header())
+	}
+	// other clients are unaffected
+	if rec := do("10.0.0.2:1234"); rec.Code != http.StatusNoContent {
+		t.Fatalf("other IP: expected 204, got %d", rec.Code)
+	}
+}
+
+func TestLoginLockoutIsProgressive(t *testing.T) {
+	mr := useMiniredis(t)
+	configureLimits(t, config.LimitsConfig{
+		Enabled: true,
+		Lockout: config.LockoutConfig{
+			Threshold:        3,
+			AccountThreshold: 10,
+			FailureWindow:    time.Hour,
+			BaseDuration:     10 * time.Second,
+			MaxDuration:      30 * time.Second,
+		},
+	})
+	ctx := context.Background()
+
+	want := []time.Duration{0, 0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		got, err := ratelimit.RecordLoginFailure(ctx, "alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Fatalf("failure %d: lockout %v, want %v", i+1, got, w)
+		}
+	}
+	if wait, _ := ratelimit.LockedOut(ctx, "alice", "10.0.0.1"); wait <= 0 {
+		t.Fatal("expected alice to be locked out")
+	}
+	if wait, _ := ratelimit.LockedOut(ctx, "bob", "10.0.0.1"); wait != 0 {
+		t.Fatal("bob should not be locked out")
+	}
+	// failures from one address don't lock the account out of others
+	if wait, _ := ratelimit.LockedOut(ctx, "alice", "10.0.0.2"); wait != 0 {
+		t.Fatal("alice should not be locked out from another address")
+	}
+
+	mr.FastForward(31 * time.Second)
+	if wait, _ := ratelimit.LockedOut(ctx, "alice", "10.0.0.1"); wait != 0 {
+		t.Fatalf("lockout should have expired, %v left", wait)
+	}
+	if err := ratelimit.ResetLoginFailures(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ratelimit.RecordLoginFailure(ctx, "alice", "10.0.0.1"); got != 0 {
+		t.Fatalf("failures should restart after reset, got lockout %v", got)
+	}
+
+	// failures spread over many addresses lock the username out everywhere, but
+	// only after the higher account threshold
+	for i := 1; i <= 10; i++ {
+		got, err := ratelimit.RecordLoginFailure(ctx, "carol", fmt.Sprintf("10.0.1.%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := time.Duration(0)
+		if i == 10 {
+			want = 10 * time.Second
+		}
+		if got != want {
+			t.Fatalf("failure %d: lockout %v, want %v", i, got, want)
+		}
+	}
+	if wait, _ := ratelimit.LockedOut(ctx, "carol", "10.0.9.9"); wait <= 0 {
+		t.Fatal("expected carol to be locked out from every address")
+	}
+}
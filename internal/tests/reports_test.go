@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/reports"
+)
+
+func TestReportCache(t *testing.T) {
+	mr := useMiniredis(t)
+	reports.Configure(config.ReportsConfig{CacheTTL: time.Minute})
+	t.Cleanup(func() { reports.Configure(config.ReportsConfig{}) })
+
+	calls := 0
+	compute := func(context.Context) (interface{}, error) {
+		calls++
+		return map[string]int{"n": calls}, nil
+	}
+	ctx := context.Background()
+	first, hit, err := reports.Get(ctx, "test", "1", compute)
+	if err != nil || hit {
+		t.Fatalf("expected a miss, got hit=%v err=%v", hit, err)
+	}
+	second, hit, err := reports.Get(ctx, "test", "1", compute)
+	if err != nil || !hit || string(second) != string(first) || calls != 1 {
+		t.Fatalf("expected a cached hit, got hit=%v calls=%d", hit, calls)
+	}
+	// other keys (e.g. tenants) are cached separately
+	if _, hit, _ := reports.Get(ctx, "test", "2", compute); hit {
+		t.Fatal("expected a miss for a different key")
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if _, hit, _ := reports.Get(ctx, "test", "1", compute); hit {
+		t.Fatal("expected the entry to expire after the TTL")
+	}
+
+	// compute errors are returned and not cached
+	boom := errors.New("boom")
+	if _, _, err := reports.Get(ctx, "test", "3", func(context.Context) (interface{}, error) { return nil, boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected compute error, got %v", err)
+	}
+	if mr.Exists("report:test:3") {
+		t.Fatal("failed report was cached")
+	}
+
+	// a Redis outage falls back to computing
+	mr.Close()
+	if _, hit, err := reports.Get(ctx, "test", "1", compute); err != nil || hit {
+		t.Fatalf("expected computed report without redis, got hit=%v err=%v", hit, err)
+	}
+}
+
+func TestAdminOrderFilters(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	admin := bearer(t, 1, 1, "admin")
+
+	get := func(token, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for url, field := range map[string]string{
+		"/api/admin/orders?status=lost":                                 "status",
+		"/api/admin/orders?customer_id=abc":                             "customer_id",
+		"/api/admin/orders/export?from=yesterday":                       "from",
+		"/api/admin/orders/export?format=xml":                           "format",
+		"/api/admin/reports/status-daily?from=2026-02-01&to=2026-01-01": "to",
+	} {
+		rec := get(admin, url)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Fatalf("%s: expected 400 on %q, got %d: %s", url, field, rec.Code, rec.Body)
+		}
+	}
+
+	customer := bearer(t, 2, 1, "customer")
+	for _, url := range []string{"/api/admin/orders/export", "/api/admin/reports/delivery-time"} {
+		if rec := get(customer, url); rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for customers, got %d", url, rec.Code)
+		}
+	}
+}
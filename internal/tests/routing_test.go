@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/couriers"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+	"github.com/rajnish-012/delivery-management-system/internal/routing"
+)
+
+// at places a stop on the equator, km kilometres east of the origin
+func at(order int, kind routing.Kind, km float64) routing.Stop {
+	return routing.Stop{OrderID: order, Kind: kind, Position: geo.Point{Lat: 0, Lng: km / 111.195}}
+}
+
+// sequence renders a route as order/kind pairs, e.g. "1p 1d"
+func sequence(r *routing.Route) string {
+	var s []string
+	for _, st := range r.Stops {
+		s = append(s, fmt.Sprintf("%d%c", st.OrderID, st.Kind[0]))
+	}
+	return strings.Join(s, " ")
+}
+
+func plannedStops(stops []routing.Stop) []routing.PlannedStop {
+	var res []routing.PlannedStop
+	for _, s := range stops {
+		res = append(res, routing.PlannedStop{Stop: s})
+	}
+	return res
+}
+
+func TestPlanPrecedence(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	origin := geo.Point{}
+	opts := routing.Options{Start: &origin, At: start, Speed: 10}
+
+	// order 1's drop-off is right next to the courier but its pickup is far away
+	stops := []routing.Stop{at(1, routing.Dropoff, 1), at(1, routing.Pickup, 5), at(2, routing.Pickup, 2), at(2, routing.Dropoff, 3)}
+	r, err := routing.Plan(stops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequence(r); got != "2p 2d 1p 1d" && got != "2p 1p 2d 1d" {
+		t.Fatalf("unexpected sequence %q", got)
+	}
+	// 5 km out and 4 km back at 10 m/s
+	if r.Distance < 8990 || r.Distance > 9010 {
+		t.Errorf("unexpected distance %f", r.Distance)
+	}
+	if want := start.Add(900 * time.Second); r.Finish.Sub(want).Abs() > time.Second {
+		t.Errorf("expected to finish at %v, got %v", want, r.Finish)
+	}
+	for k, s := range r.Stops {
+		if k > 0 && s.Arrival.Before(r.Stops[k-1].Arrival) {
+			t.Fatalf("arrivals out of order: %+v", r.Stops)
+		}
+	}
+
+	// a drop-off without its pickup has been picked up already
+	r, err = routing.Plan([]routing.Stop{at(3, routing.Dropoff, 4), at(4, routing.Pickup, 1), at(4, routing.Dropoff, 2)}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequence(r); got != "4p 4d 3d" {
+		t.Fatalf("unexpected sequence %q", got)
+	}
+
+	r, err = routing.Plan(nil, opts)
+	if err != nil || len(r.Stops) != 0 || !r.Finish.Equal(start) {
+		t.Fatalf("expected an empty route, got %+v, %v", r, err)
+	}
+}
+
+func TestPlanTimeWindows(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	origin := geo.Point{}
+	opts := routing.Options{Start: &origin, At: start, Speed: 10, StopTime: time.Minute}
+	window := func(s routing.Stop, from, to time.Duration) routing.Stop {
+		if from > 0 {
+			e := start.Add(from)
+			s.Earliest = &e
+		}
+		if to > 0 {
+			l := start.Add(to)
+			s.Latest = &l
+		}
+		return s
+	}
+
+	// the far drop-off is due before the courier could pass the near one on the
+	// way, so the near one waits: 800 s of travel and two pickups make 920 s
+	stops := []routing.Stop{
+		at(1, routing.Pickup, 1), window(at(1, routing.Dropoff, 2), 0, 2*time.Hour),
+		at(2, routing.Pickup, 1), window(at(2, routing.Dropoff, 8), 0, 950*time.Second),
+	}
+	r, err := routing.Plan(stops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stops[len(r.Stops)-1].OrderID != 1 {
+		t.Fatalf("expected order 2 to be delivered first, got %q", sequence(r))
+	}
+	for _, s := range r.Stops {
+		if s.Late {
+			t.Errorf("stop %+v is late", s)
+		}
+	}
+
+	// an early courier waits for the window to open; the route stays the same
+	stops = []routing.Stop{at(1, routing.Pickup, 1), window(at(1, routing.Dropoff, 2), time.Hour, 0)}
+	r, err = routing.Plan(stops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(time.Hour + time.Minute); !r.Finish.Equal(want) {
+		t.Errorf("expected to finish at %v, got %v", want, r.Finish)
+	}
+	if r.Stops[1].Arrival.After(start.Add(time.Hour)) {
+		t.Errorf("expected to arrive before the window, got %v", r.Stops[1].Arrival)
+	}
+
+	// a window that can't be met is still planned, and flagged
+	stops = []routing.Stop{at(1, routing.Pickup, 1), window(at(1, routing.Dropoff, 20), 0, time.Minute)}
+	r, err = routing.Plan(stops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Stops[1].Late || r.Stops[0].Late {
+		t.Fatalf("expected only the drop-off to be late, got %+v", r.Stops)
+	}
+}
+
+func TestPlanTwoOpt(t *testing.T) {
+	// drop-offs at the corners of a square, starting from one corner: nearest
+	// neighbour goes along the edge, then 2-opt has to avoid crossing the middle
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	corner := func(order int, lat, lng float64) routing.Stop {
+		return routing.Stop{OrderID: order, Kind: routing.Dropoff, Position: geo.Point{Lat: lat, Lng: lng}}
+	}
+	stops := []routing.Stop{corner(1, 0, 0.01), corner(2, 0.01, 0), corner(3, 0.01, 0.01), corner(4, 0, 0.02), corner(5, 0.01, 0.02)}
+	origin := geo.Point{}
+	r, err := routing.Plan(stops, routing.Options{Start: &origin, At: start, Speed: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// visiting the five points in any order covers at least 5 edges of ~1.1 km;
+	// crossing a diagonal costs more
+	if r.Distance > 5*1112+50 {
+		t.Fatalf("route is longer than it needs to be: %f m, %q", r.Distance, sequence(r))
+	}
+	seen := make(map[int]bool)
+	for _, s := range r.Stops {
+		seen[s.OrderID] = true
+	}
+	if len(seen) != len(stops) {
+		t.Fatalf("expected every stop once, got %+v", r.Stops)
+	}
+}
+
+func TestPlanInvalid(t *testing.T) {
+	opts := routing.Options{At: time.Now(), Speed: 5}
+	for name, stops := range map[string][]routing.Stop{
+		"two pickups":   {at(1, routing.Pickup, 1), at(1, routing.Pickup, 2)},
+		"two drop-offs": {at(1, routing.Dropoff, 1), at(1, routing.Dropoff, 2)},
+		"unknown kind":  {at(1, "detour", 1)},
+	} {
+		if _, err := routing.Plan(stops, opts); !errors.Is(err, routing.ErrInvalidStops) {
+			t.Errorf("%s: expected ErrInvalidStops, got %v", name, err)
+		}
+	}
+	if _, err := routing.Plan(nil, routing.Options{}); err == nil {
+		t.Error("expected an error without a speed")
+	}
+}
+
+func TestCourierStops(t *testing.T) {
+	p := &geo.Point{Lat: 1, Lng: 1}
+	after := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	stops := couriers.Stops([]*models.Order{
+		{ID: 1, Status: "created", Pickup: p, Dropoff: p, DeliverAfter: &after},
+		{ID: 2, Status: "in_transit", Pickup: p, Dropoff: p},
+		{ID: 3, Status: "delivered", Pickup: p, Dropoff: p},
+		{ID: 4, Status: "dispatched"},
+	})
+	if got := sequence(&routing.Route{Stops: plannedStops(stops)}); got != "1p 1d 2d" {
+		t.Fatalf("unexpected stops %q", got)
+	}
+	if stops[1].Earliest == nil || !stops[1].Earliest.Equal(after) || stops[0].Earliest != nil {
+		t.Fatalf("expected the delivery window on the drop-off only, got %+v", stops)
+	}
+}
+
+func TestCourierValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	courier := bearer(t, 3, 1, "courier")
+	customer := bearer(t, 2, 1, "customer")
+	admin := bearer(t, 1, 1, "admin")
+
+	for _, path := range []string{"/api/courier/route", "/api/courier/position"} {
+		method := http.MethodGet
+		if strings.HasSuffix(path, "position") {
+			method = http.MethodPut
+		}
+		if rec := call(method, path, customer, `{"lat":1,"lng":1}`); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for a customer, got %d", path, rec.Code)
+		}
+	}
+	for name, body := range map[string]string{
+		"missing lng":  `{"lat":1}`,
+		"out of range": `{"lat":91,"lng":0}`,
+	} {
+		if rec := call(http.MethodPut, "/api/courier/position", courier, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := call(http.MethodPut, "/api/admin/orders/1/courier", courier, `{"courier_id":3}`); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a courier, got %d", rec.Code)
+	}
+	rec := call(http.MethodPut, "/api/admin/orders/1/courier", admin, `{"courier_id":0}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"courier_id"`) {
+		t.Errorf("expected a courier_id error, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = call(http.MethodPost, "/api/orders", customer,
+		`{"item":"book","deliver_after":"2026-01-05T12:00:00Z","deliver_before":"2026-01-05T11:00:00Z"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"deliver_before"`) {
+		t.Errorf("expected a delivery window error, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/orders"
+)
+
+func TestCheckTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		override bool
+		ok       bool
+	}{
+		{"created", "dispatched", false, true},
+		{"in_transit", "delivered", false, true},
+		{"dispatched", "cancelled", false, true},
+		{"created", "delivered", false, false},
+		{"created", "delivered", true, true},
+		{"delivered", "dispatched", false, false},
+		{"delivered", "dispatched", true, true},
+		{"cancelled", "created", true, true},
+		// never allowed, even with override
+		{"delivered", "cancelled", true, false},
+		{"cancelled", "delivered", true, false},
+		{"dispatched", "dispatched", true, false},
+		{"created", "lost", true, false},
+	} {
+		err := orders.CheckTransition(tc.from, tc.to, tc.override)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s -> %s (override=%v): got %v, want ok=%v", tc.from, tc.to, tc.override, err, tc.ok)
+		}
+	}
+	if err := orders.CheckTransition("created", "delivered", false); err == nil || !strings.Contains(err.Error(), "without override") {
+		t.Errorf("expected a hint that override is needed, got %v", err)
+	}
+}
+
+func TestSetOrderStatusValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/orders/1/status", strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(bearer(t, 1, 1, "customer"), `{"status":"delivered","reason":"glitch"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("customer: expected 403, got %d", rec.Code)
+	}
+	admin := bearer(t, 1, 1, "admin")
+	for name, tc := range map[string]struct{ body, field string }{
+		"no reason":      {`{"status":"delivered"}`, "reason"},
+		"blank reason":   {`{"status":"delivered","reason":"  "}`, "reason"},
+		"unknown status": {`{"status":"lost","reason":"glitch"}`, "status"},
+	} {
+		rec := post(admin, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"field":"`+tc.field+`"`) {
+			t.Fatalf("%s: expected error on %q, got %s", name, tc.field, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+func TestTenantScope(t *testing.T) {
+	ctx := context.Background()
+
+	// unscoped contexts never reach the database
+	err := database.Scoped(ctx, func(pgx.Tx) error { return nil })
+	if !errors.Is(err, database.ErrNoScope) {
+		t.Fatalf("expected ErrNoScope, got %v", err)
+	}
+
+	tenant := database.WithTenant(ctx, 7)
+	if id, ok := database.TenantID(tenant); !ok || id != 7 {
+		t.Fatalf("expected tenant 7, got %d %v", id, ok)
+	}
+	if got := database.TenantFilter(tenant); got != 7 {
+		t.Fatalf("expected filter 7, got %v", got)
+	}
+
+	system := database.WithSystem(ctx)
+	if _, ok := database.TenantID(system); ok {
+		t.Fatal("system scope should have no tenant")
+	}
+	if got := database.TenantFilter(system); got != nil {
+		t.Fatalf("system scope should not filter, got %v", got)
+	}
+
+	// background work keeps the tenant of the request that started it
+	copied := database.CopyScope(context.Background(), tenant)
+	if id, _ := database.TenantID(copied); id != 7 {
+		t.Fatalf("expected copied tenant 7, got %d", id)
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/auth"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890", base32-encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, last six of the eight digits
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := auth.TOTPCode(rfcSecret, auth.TOTPStep(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("at %d: got %q %v, want %q", unix, got, err, want)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	step := auth.TOTPStep(now)
+	for offset, ok := range map[int64]bool{-2: false, -1: true, 0: true, 1: true, 2: false} {
+		code, _ := auth.TOTPCode(rfcSecret, step+offset)
+		got, valid := auth.CheckTOTP(rfcSecret, code, now)
+		if valid != ok || (ok && got != step+offset) {
+			t.Errorf("offset %d: got step %d valid=%v", offset, got, valid)
+		}
+	}
+	if _, ok := auth.CheckTOTP(rfcSecret, "12345", now); ok {
+		t.Error("accepted a short code")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := auth.NewTOTPSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("unexpected secret %q: %v", secret, err)
+	}
+	u, err := url.Parse(auth.ProvisioningURI("Delivery Management", "alice", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Delivery Management:alice" ||
+		q.Get("secret") != secret || q.Get("issuer") != "Delivery Management" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected uri %s", u)
+	}
+}
+
+func TestLoginChallenge(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+
+	challenge, err := auth.StartLoginChallenge(ctx, &models.User{ID: 3, TenantID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := mr.Keys()
+	if len(keys) != 1 || strings.Contains(keys[0], challenge) {
+		t.Fatalf("expected one challenge stored by hash, got %v", keys)
+	}
+	if ttl := mr.TTL(keys[0]); ttl <= 0 || ttl > 5*time.Minute {
+		t.Fatalf("unexpected challenge ttl %v", ttl)
+	}
+
+	// attempts are capped; the last one allowed has been used up
+	mr.HSet(keys[0], "attempts", "5")
+	if _, err := auth.CompleteLoginChallenge(ctx, challenge, "123456"); !errors.Is(err, auth.ErrChallengeExpired) {
+		t.Fatalf("expected the challenge used up, got %v", err)
+	}
+	if mr.Exists(keys[0]) {
+		t.Fatal("used up challenge still stored")
+	}
+
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"challenge":"unknown","code":"123456"}`))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unknown challenge, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Fatalf("unknown challenge left keys behind: %v", mr.Keys())
+	}
+}
+
+func TestAdminRequiresTwoFactor(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	bearer(t, 1, 1, "admin") // configures auth
+	passwordOnly, err := auth.GenerateToken(1, 1, "admin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, route := range [][2]string{
+		{http.MethodGet, "/api/admin/orders"},
+		{http.MethodPost, "/api/admin/orders/x/status"},
+		{http.MethodGet, "/api/admin/reports/status-daily"},
+		{http.MethodPost, "/api/admin/merchants"},
+		{http.MethodDelete, "/api/admin/api-keys/1"},
+		{http.MethodGet, "/api/orders"},
+		{http.MethodPost, "/api/orders/1/cancel"},
+		{http.MethodPut, "/api/me"},
+	} {
+		rec := call(route[0], route[1], "Bearer "+passwordOnly)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "two-factor") {
+			t.Errorf("%s %s: expected 403 asking for two-factor, got %d: %s", route[0], route[1], rec.Code, rec.Body)
+		}
+	}
+	// with two-factor the request reaches the handler, which rejects the bad id
+	if rec := call(http.MethodPost, "/api/admin/orders/x/status", bearer(t, 1, 1, "admin")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the handler's 400, got %d: %s", rec.Code, rec.Body)
+	}
+	// customers are still turned away by the admin check itself
+	if rec := call(http.MethodGet, "/api/admin/orders", bearer(t, 2, 1, "customer")); rec.Code != http.StatusForbidden || strings.Contains(rec.Body.String(), "two-factor") {
+		t.Fatalf("expected a plain 403 for a customer, got %d: %s", rec.Code, rec.Body)
+	}
+	// enrolling stays open to a password-only admin session
+	if rec := call(http.MethodPost, "/api/me/2fa/confirm", "Bearer "+passwordOnly); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the confirm handler's 400, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/config"
+	"github.com/rajnish-012/delivery-management-system/internal/database"
+)
+
+func TestAdminUsersValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	admin := bearer(t, 1, 1, "admin")
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", admin)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := call(http.MethodGet, "/api/admin/users?role=owner&disabled=maybe&limit=500&after=-1", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, field := range []string{"role", "disabled", "limit", "after"} {
+		if !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("expected an error on %q, got %s", field, rec.Body)
+		}
+	}
+	if rec := call(http.MethodPut, "/api/admin/users/2/role", `{"role":"owner"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown role, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// admins can't lock themselves out
+	for _, tc := range [][3]string{
+		{http.MethodPut, "/api/admin/users/1/role", `{"role":"customer"}`},
+		{http.MethodPost, "/api/admin/users/1/disable", ""},
+	} {
+		if rec := call(tc[0], tc[1], tc[2]); rec.Code != http.StatusConflict {
+			t.Errorf("%s %s: expected 409, got %d: %s", tc[0], tc[1], rec.Code, rec.Body)
+		}
+	}
+
+	rec = call(http.MethodPost, "/api/admin/users", `{"username":"bob","password":"Secret123","role":"owner","merchant":"Acme Ltd"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, field := range []string{"role", "merchant"} {
+		if !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("expected an error on %q, got %s", field, rec.Body)
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req := httptest.NewRequest(method, "/api/admin/users", strings.NewReader(`{"username":"bob","password":"Secret123","role":"admin"}`))
+		req.Header.Set("Authorization", bearer(t, 2, 1, "customer"))
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 for a customer, got %d", method, rec.Code)
+		}
+	}
+}
+
+func TestRegisterCantCreateAdmins(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"username":"mallory","password":"Secret123","role":"admin"}`))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"role"`) {
+		t.Fatalf("expected the admin role rejected, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// unreachablePostgres points database.Pool at a server that refuses connections,
+// so session lookups are answered by the cache or fail
+func unreachablePostgres(t *testing.T) {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://127.0.0.1:1/none?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := database.Pool
+	database.Pool = pool
+	t.Cleanup(func() {
+		database.Pool = prev
+		pool.Close()
+	})
+}
+
+func TestDisabledUserTokensRefused(t *testing.T) {
+	mr := useMiniredis(t)
+	configureCache(t, config.CacheConfig{Enabled: true, TTL: time.Minute})
+	unreachablePostgres(t)
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	token := bearer(t, 5, 1, "customer")
+	call := func() *httptest.ResponseRecorder {
+		// an invalid body gets a 400 from the handler without touching the database
+		req := httptest.NewRequest(http.MethodPut, "/api/me", strings.NewReader(`{"email":"x"}`))
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	mr.Set("cache:session:1:5", `{"disabled":true,"revoked_at":null}`)
+	if rec := call(); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "disabled") {
+		t.Fatalf("expected the disabled user refused, got %d: %s", rec.Code, rec.Body)
+	}
+	mr.Set("cache:session:1:5", `{"disabled":false,"revoked_at":null}`)
+	if rec := call(); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the re-enabled user let through, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// with nothing cached the check falls through to Postgres, which is down
+	mr.Del("cache:session:1:5")
+	if rec := call(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the request refused when the session can't be checked, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := mr.Get("cache:session:1:5"); err == nil {
+		t.Fatal("a failed lookup must not be cached")
+	}
+}
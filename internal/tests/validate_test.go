@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rajnish-012/delivery-management-system/internal/validate"
+)
+
+type signup struct {
+	Username string `json:"username" validate:"required,min=3,max=32,username"`
+	Password string `json:"password" validate:"required,min=8,maxbytes=72,password"`
+	Role     string `json:"role" validate:"required,oneof=customer admin"`
+	Note     string `json:"note" validate:"max=5"`
+	Merchant string `json:"merchant" validate:"max=64,slug"`
+	Email    string `json:"email" validate:"max=254,email"`
+	Phone    string `json:"phone" validate:"phone"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     signup
+		fields []string
+	}{
+		{"valid", signup{Username: "alice_1", Password: "secret123", Role: "customer"}, nil},
+		{"missing all", signup{}, []string{"username", "password", "role"}},
+		{"blank username", signup{Username: "   ", Password: "secret123", Role: "admin"}, []string{"username"}},
+		{"bad username chars", signup{Username: "al ice", Password: "secret123", Role: "admin"}, []string{"username"}},
+		{"username too short", signup{Username: "al", Password: "secret123", Role: "admin"}, []string{"username"}},
+		{"weak password", signup{Username: "alice", Password: "abcdefgh", Role: "admin"}, []string{"password"}},
+		{"short password", signup{Username: "alice", Password: "abc1", Role: "admin"}, []string{"password"}},
+		// 37 runes in 72 bytes pass; one more two-byte rune is past bcrypt's limit
+		{"multibyte password", signup{Username: "alice", Password: strings.Repeat("é", 35) + "a1", Role: "admin"}, nil},
+		{"multibyte password too long", signup{Username: "alice", Password: strings.Repeat("é", 36) + "a1", Role: "admin"}, []string{"password"}},
+		{"bad role", signup{Username: "alice", Password: "secret123", Role: "root"}, []string{"role"}},
+		{"bad merchant slug", signup{Username: "alice", Password: "secret123", Role: "admin", Merchant: "Acme Ltd"}, []string{"merchant"}},
+		{"valid contact", signup{Username: "alice", Password: "secret123", Role: "customer", Email: "alice@example.com", Phone: "+4915112345678"}, nil},
+		{"bad email", signup{Username: "alice", Password: "secret123", Role: "customer", Email: "Alice <alice@example.com>"}, []string{"email"}},
+		{"bad phone", signup{Username: "alice", Password: "secret123", Role: "customer", Phone: "0151 1234567"}, []string{"phone"}},
+		{"optional too long", signup{Username: "alice", Password: "secret123", Role: "admin", Note: "toolong"}, []string{"note"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validate.Struct(&tc.in)
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var verrs validate.Errors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("expected validate.Errors, got %v", err)
+			}
+			if len(verrs) != len(tc.fields) {
+				t.Fatalf("expected fields %v, got %v", tc.fields, verrs)
+			}
+			for i, f := range tc.fields {
+				if verrs[i].Field != f {
+					t.Fatalf("expected field %q at %d, got %q", f, i, verrs[i].Field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rajnish-012/delivery-management-system/internal/api"
+	"github.com/rajnish-012/delivery-management-system/internal/geo"
+	"github.com/rajnish-012/delivery-management-system/internal/models"
+)
+
+// poly builds a polygon from lat, lng pairs
+func poly(coords ...float64) geo.Polygon {
+	var pg geo.Polygon
+	for i := 0; i+1 < len(coords); i += 2 {
+		pg = append(pg, geo.Point{Lat: coords[i], Lng: coords[i+1]})
+	}
+	return pg
+}
+
+func TestPolygon(t *testing.T) {
+	// an L shape, so the notch at the top right is outside
+	l := poly(0, 0, 0, 2, 1, 2, 1, 1, 2, 1, 2, 0)
+	if err := l.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		p    geo.Point
+		want bool
+	}{
+		{geo.Point{Lat: 0.5, Lng: 0.5}, true},
+		{geo.Point{Lat: 0.5, Lng: 1.5}, true},
+		{geo.Point{Lat: 1.5, Lng: 0.5}, true},
+		{geo.Point{Lat: 1.5, Lng: 1.5}, false},
+		{geo.Point{Lat: 0, Lng: 1}, true}, // on an edge
+		{geo.Point{Lat: 2, Lng: 0}, true}, // a vertex
+		{geo.Point{Lat: -0.1, Lng: 0.5}, false},
+		{geo.Point{Lat: 0.5, Lng: 3}, false},
+	} {
+		if got := l.Contains(tc.p); got != tc.want {
+			t.Errorf("Contains(%v) = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+	closed := append(geo.Polygon{}, l...)
+	closed = append(closed, l[0])
+	if err := closed.Validate(); err != nil || !closed.Contains(geo.Point{Lat: 0.5, Lng: 0.5}) {
+		t.Fatalf("a closing vertex should be allowed: %v", err)
+	}
+
+	for name, pg := range map[string]geo.Polygon{
+		"too few":      poly(0, 0, 0, 1, 0, 0),
+		"bad vertex":   poly(0, 0, 0, 1, 91, 1),
+		"repeated":     poly(0, 0, 0, 1, 0, 1, 1, 1),
+		"no area":      poly(0, 0, 0, 1, 0, 2),
+		"bow tie":      poly(0, 0, 1, 1, 1, 0, 0, 1),
+		"nan":          poly(0, 0, 0, 1, math.NaN(), 1),
+		"touches self": poly(0, 0, 0, 2, 1, 1, 2, 2, 2, 0, 1, 1),
+	} {
+		if pg.Validate() == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+	big := make(geo.Polygon, geo.MaxVertices+1)
+	for i := range big {
+		a := 2 * math.Pi * float64(i) / float64(len(big))
+		big[i] = geo.Point{Lat: math.Sin(a), Lng: math.Cos(a)}
+	}
+	if big.Validate() == nil {
+		t.Error("expected an error for too many vertices")
+	}
+
+	// a degree of latitude is about 111 km
+	if d := geo.Distance(geo.Point{Lat: 10, Lng: 20}, geo.Point{Lat: 11, Lng: 20}); math.Abs(d-111195) > 10 {
+		t.Errorf("unexpected distance %f", d)
+	}
+}
+
+func TestZoneAt(t *testing.T) {
+	square := func(lat, lng float64) geo.Polygon {
+		return poly(lat, lng, lat, lng+1, lat+1, lng+1, lat+1, lng)
+	}
+	zones := []*models.Zone{
+		{ID: 1, Polygon: square(0, 0), Active: false},
+		{ID: 2, Polygon: square(0, 0), Active: true},
+		{ID: 3, Polygon: square(0.5, 0.5), Active: true},
+	}
+	if z := models.ZoneAt(zones, geo.Point{Lat: 0.7, Lng: 0.7}); z == nil || z.ID != 2 {
+		t.Fatalf("expected the oldest active zone, got %+v", z)
+	}
+	if z := models.ZoneAt(zones, geo.Point{Lat: 1.2, Lng: 1.2}); z == nil || z.ID != 3 {
+		t.Fatalf("expected zone 3, got %+v", z)
+	}
+	if z := models.ZoneAt(zones, geo.Point{Lat: 5, Lng: 5}); z != nil {
+		t.Fatalf("expected no zone, got %+v", z)
+	}
+}
+
+func TestZoneValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	admin := bearer(t, 1, 1, "admin")
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", admin)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	square := `[{"lat":0,"lng":0},{"lat":0,"lng":1},{"lat":1,"lng":1},{"lat":1,"lng":0}]`
+	for name, tc := range map[string]struct{ body, field string }{
+		"no name":       {`{"polygon":` + square + `}`, "name"},
+		"no polygon":    {`{"name":"centre"}`, "polygon"},
+		"bow tie":       {`{"name":"centre","polygon":[{"lat":0,"lng":0},{"lat":1,"lng":1},{"lat":1,"lng":0},{"lat":0,"lng":1}]}`, "polygon"},
+		"unknown field": {`{"name":"centre","polygon":` + square + `,"colour":"red"}`, "colour"},
+	} {
+		for _, m := range [][2]string{{http.MethodPost, "/api/admin/zones"}, {http.MethodPut, "/api/admin/zones/1"}} {
+			rec := call(m[0], m[1], tc.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s %s: expected 400, got %d: %s", m[0], name, rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), `"field":"`+tc.field+`"`) {
+				t.Fatalf("%s %s: expected error on %q, got %s", m[0], name, tc.field, rec.Body)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/zones", strings.NewReader(`{"name":"centre","polygon":`+square+`}`))
+	req.Header.Set("Authorization", bearer(t, 2, 1, "customer"))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a customer, got %d", rec.Code)
+	}
+}
+
+func TestOrderPositionValidation(t *testing.T) {
+	r := mux.NewRouter()
+	api.RegisterRoutes(r)
+	token := bearer(t, 1, 1, "customer")
+
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/api/orders", "application/json", `{"item":"book","pickup":{"lat":95,"lng":0},"dropoff":{"lat":0,"lng":200}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	for _, field := range []string{"pickup", "dropoff"} {
+		if !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("expected an error on %q, got %s", field, rec.Body)
+		}
+	}
+
+	// bad positions fail their own row; none of these reach the database
+	rec = post("/api/orders/batch", "text/csv",
+		"item,pickup_lat,pickup_lng,dropoff_lat,dropoff_lng\nbook,north,0,1,1\nbook,0,0,1,\nbook,0,0,-91,1\n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"failed":3`) {
+		t.Fatalf("expected every row to fail, got %s", rec.Body)
+	}
+	for _, field := range []string{`"field":"pickup"`, `"field":"dropoff"`} {
+		if !strings.Contains(rec.Body.String(), field) {
+			t.Errorf("expected an error on %s, got %s", field, rec.Body)
+		}
+	}
+	rec = post("/api/orders/batch", "text/csv", "item,pickup_lat,colour\nbook,1,red\n")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"header"`) {
+		t.Fatalf("expected a header error, got %d: %s", rec.Code, rec.Body)
+	}
+}
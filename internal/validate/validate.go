@@ -0,0 +1,215 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError describes a single invalid field in a request payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of field errors returned by Struct
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+var (
+	usernameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	slugRe     = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	phoneRe    = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// Struct validates v (a struct or pointer to struct) against its `validate` tags.
+//
+// Supported rules, comma separated:
+//
+//	required     non-zero value (strings must contain non-space characters)
+//	min=N,max=N  length bounds for strings, value bounds for integers
+//	maxbytes=N   at most N bytes of UTF-8, e.g. bcrypt's 72 byte limit on passwords
+//	oneof=a b c  value must be one of the space separated options
+//	username     letters, digits, '_', '.', '-' and must start alphanumeric
+//	password     at least one letter and one digit
+//	slug         lowercase letters, digits and '-', must start alphanumeric
+//	email        a bare address such as name@example.com
+//	phone        E.164 number such as +4915112345678
+//
+// Field names in the returned Errors come from the `json` tag.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return Errors{{Field: "body", Message: "is required"}}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected struct, got %s", rv.Kind())
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+		name := fieldName(sf)
+		if msg := checkField(rv.Field(i), tag); msg != "" {
+			errs = append(errs, FieldError{Field: name, Message: msg})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if j := sf.Tag.Get("json"); j != "" {
+		if n := strings.Split(j, ",")[0]; n != "" && n != "-" {
+			return n
+		}
+	}
+	return sf.Name
+}
+
+// checkField applies the rules in tag to fv and returns the first failure message.
+func checkField(fv reflect.Value, tag string) string {
+	rules := strings.Split(tag, ",")
+
+	// empty optional fields skip all other rules
+	if isZero(fv) {
+		for _, r := range rules {
+			if r == "required" {
+				return "is required"
+			}
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			// already handled above
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic("validate: bad " + name + " argument " + strconv.Quote(arg))
+			}
+			if msg := checkBound(fv, name, n); msg != "" {
+				return msg
+			}
+		case "maxbytes":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic("validate: bad maxbytes argument " + strconv.Quote(arg))
+			}
+			if len(fv.String()) > n {
+				return fmt.Sprintf("must be at most %d bytes", n)
+			}
+		case "oneof":
+			opts := strings.Fields(arg)
+			s := fmt.Sprint(fv.Interface())
+			found := false
+			for _, o := range opts {
+				if o == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return "must be one of: " + strings.Join(opts, ", ")
+			}
+		case "username":
+			if !usernameRe.MatchString(fv.String()) {
+				return "may contain only letters, digits, '_', '.' and '-' and must start with a letter or digit"
+			}
+		case "password":
+			if !hasLetterAndDigit(fv.String()) {
+				return "must contain at least one letter and one digit"
+			}
+		case "slug":
+			if !slugRe.MatchString(fv.String()) {
+				return "may contain only lowercase letters, digits and '-' and must start with a letter or digit"
+			}
+		case "email":
+			if a, err := mail.ParseAddress(fv.String()); err != nil || a.Address != fv.String() {
+				return "must be an email address"
+			}
+		case "phone":
+			if !phoneRe.MatchString(fv.String()) {
+				return "must be an E.164 phone number such as +4915112345678"
+			}
+		default:
+			panic("validate: unknown rule " + strconv.Quote(name))
+		}
+	}
+	return ""
+}
+
+func isZero(fv reflect.Value) bool {
+	if fv.Kind() == reflect.String {
+		return strings.TrimSpace(fv.String()) == ""
+	}
+	return fv.IsZero()
+}
+
+func checkBound(fv reflect.Value, rule string, n int) string {
+	switch fv.Kind() {
+	case reflect.String:
+		l := utf8.RuneCountInString(fv.String())
+		if rule == "min" && l < n {
+			return fmt.Sprintf("must be at least %d characters", n)
+		}
+		if rule == "max" && l > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := fv.Int()
+		if rule == "min" && v < int64(n) {
+			return fmt.Sprintf("must be at least %d", n)
+		}
+		if rule == "max" && v > int64(n) {
+			return fmt.Sprintf("must be at most %d", n)
+		}
+	case reflect.Slice:
+		l := fv.Len()
+		if rule == "min" && l < n {
+			return fmt.Sprintf("must contain at least %d items", n)
+		}
+		if rule == "max" && l > n {
+			return fmt.Sprintf("must contain at most %d items", n)
+		}
+	}
+	return ""
+}
+
+func hasLetterAndDigit(s string) bool {
+	var letter, digit bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return letter && digit
+}
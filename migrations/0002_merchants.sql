@@ -0,0 +1,66 @@
+-- merchants (tenants); every user, order and API key belongs to exactly one
+CREATE TABLE IF NOT EXISTS merchants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- merchant 1 is the platform operator and owns all pre-existing data
+INSERT INTO merchants (id, slug, name) VALUES (1, 'default', 'Default merchant')
+ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('merchants', 'id'), GREATEST((SELECT MAX(id) FROM merchants), 1));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id INTEGER REFERENCES merchants(id);
+UPDATE users SET tenant_id = 1 WHERE tenant_id IS NULL;
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users(tenant_id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tenant_id INTEGER REFERENCES merchants(id);
+UPDATE orders o SET tenant_id = u.tenant_id FROM users u WHERE o.customer_id = u.id AND o.tenant_id IS NULL;
+UPDATE orders SET tenant_id = 1 WHERE tenant_id IS NULL;
+ALTER TABLE orders ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_id ON orders(tenant_id, created_at DESC);
+
+-- server-to-server keys; only the SHA-256 of the key is stored
+CREATE TABLE IF NOT EXISTS merchant_api_keys (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_merchant_api_keys_tenant_id ON merchant_api_keys(tenant_id);
+
+-- Row-level security backstop. The application sets app.tenant_id (or app.system for
+-- cross-tenant work such as login lookups and the progression worker) per transaction.
+-- Superusers always bypass RLS, so production must connect as a non-superuser role.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON orders;
+CREATE POLICY tenant_isolation ON orders
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE merchant_api_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE merchant_api_keys FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON merchant_api_keys;
+CREATE POLICY tenant_isolation ON merchant_api_keys
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
@@ -0,0 +1,6 @@
+-- API keys: permission scopes, expiry and last-used tracking.
+-- Keys issued before scopes existed keep the access they had.
+ALTER TABLE merchant_api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{orders:read,orders:write}';
+ALTER TABLE merchant_api_keys ALTER COLUMN scopes DROP DEFAULT;
+ALTER TABLE merchant_api_keys ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE merchant_api_keys ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
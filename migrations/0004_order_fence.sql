@@ -0,0 +1,4 @@
+-- Fencing token of the last progression lease holder that wrote each order.
+-- Status writes carrying an older token are rejected, so a replica whose lease
+-- expired without it noticing cannot overwrite newer state.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS fence BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- Audit trail of manual status changes made by admins, with who made them and why.
+CREATE TABLE IF NOT EXISTS order_status_changes (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    override BOOLEAN NOT NULL,
+    changed_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS order_status_changes_order_idx ON order_status_changes (order_id);
+
+ALTER TABLE order_status_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_status_changes FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON order_status_changes;
+CREATE POLICY tenant_isolation ON order_status_changes
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
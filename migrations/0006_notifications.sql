@@ -0,0 +1,26 @@
+-- Customer notifications: contact details on users, and per-user channel
+-- preferences and quiet hours. Users without a preferences row get the defaults.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT;
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    email_enabled BOOLEAN NOT NULL DEFAULT true,
+    sms_enabled BOOLEAN NOT NULL DEFAULT false,
+    locale TEXT NOT NULL DEFAULT 'en',
+    -- quiet hours are HH:MM in timezone; both set or both NULL
+    quiet_start TEXT,
+    quiet_end TEXT,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+ALTER TABLE notification_preferences ENABLE ROW LEVEL SECURITY;
+ALTER TABLE notification_preferences FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON notification_preferences;
+CREATE POLICY tenant_isolation ON notification_preferences
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
@@ -0,0 +1,23 @@
+-- Display names, and single-use password reset tokens. Only a SHA-256 of each
+-- token is stored; the token itself is sent to the user and never kept.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name TEXT;
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    token_hash TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_idx ON password_reset_tokens (user_id);
+
+ALTER TABLE password_reset_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE password_reset_tokens FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON password_reset_tokens;
+CREATE POLICY tenant_isolation ON password_reset_tokens
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication. totp_secret is set at enrollment and only
+-- counts once totp_enabled is; totp_last_step is the last time step a code was
+-- accepted for, so a code can't be replayed. Recovery codes are stored as SHA-256.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, code_hash)
+);
+
+ALTER TABLE recovery_codes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE recovery_codes FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON recovery_codes;
+CREATE POLICY tenant_isolation ON recovery_codes
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
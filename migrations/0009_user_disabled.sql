@@ -0,0 +1,3 @@
+-- Admins can disable accounts; a disabled user can't log in and their tokens stop
+-- working. NULL means the account is active.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
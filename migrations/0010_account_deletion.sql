@@ -0,0 +1,5 @@
+-- Deleted accounts keep their row, anonymized, so orders still reference a
+-- customer for accounting. last_login_at lets the retention job find inactive
+-- accounts; NULL means never logged in since it was added.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,30 @@
+-- Service zones: the areas a merchant delivers in, as polygons of {lat, lng}
+-- vertices. Containment is tested in Go, so no PostGIS is needed. Orders get
+-- their pickup and drop-off positions and the zone they were accepted in;
+-- orders from before this migration have none.
+CREATE TABLE IF NOT EXISTS service_zones (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    name TEXT NOT NULL,
+    polygon JSONB NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE (tenant_id, name)
+);
+
+ALTER TABLE service_zones ENABLE ROW LEVEL SECURITY;
+ALTER TABLE service_zones FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON service_zones;
+CREATE POLICY tenant_isolation ON service_zones
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_lat DOUBLE PRECISION;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_lng DOUBLE PRECISION;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dropoff_lat DOUBLE PRECISION;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dropoff_lng DOUBLE PRECISION;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS zone_id INTEGER REFERENCES service_zones(id);
+CREATE INDEX IF NOT EXISTS orders_zone_idx ON orders (zone_id);
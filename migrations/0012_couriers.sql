@@ -0,0 +1,29 @@
+-- Couriers: users with the courier role. Their last reported position and their
+-- planned route live in couriers; a courier without a row has reported nothing and
+-- has no route yet. Orders get the courier they are assigned to and an optional
+-- delivery window.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('customer','admin','courier'));
+
+CREATE TABLE IF NOT EXISTS couriers (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    tenant_id INTEGER NOT NULL REFERENCES merchants(id),
+    lat DOUBLE PRECISION,
+    lng DOUBLE PRECISION,
+    position_at TIMESTAMP WITH TIME ZONE,
+    route JSONB
+);
+
+ALTER TABLE couriers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE couriers FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON couriers;
+CREATE POLICY tenant_isolation ON couriers
+    USING (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int)
+    WITH CHECK (current_setting('app.system', true) = 'on'
+           OR tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::int);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS courier_id INTEGER REFERENCES users(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deliver_after TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deliver_before TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS orders_courier_idx ON orders (courier_id) WHERE courier_id IS NOT NULL;
@@ -0,0 +1,14 @@
+-- When each order reached dispatched, in transit and delivered, so delivery
+-- estimates can learn how long orders spend in each status, and the latest
+-- estimate itself. Delivered orders from before this migration take updated_at as
+-- their delivery time; they have no earlier timestamps and don't count toward the
+-- estimates.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dispatched_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS in_transit_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS eta TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS eta_confidence DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE orders SET delivered_at = updated_at WHERE status = 'delivered' AND delivered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS orders_delivered_at_idx ON orders (tenant_id, delivered_at) WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Tokens issued to a user before sessions_revoked_at are refused: a password
+-- change or reset, a role change and a forced logout set it. NULL means no
+-- session was ever revoked.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,4 @@
+-- Routes are planned outside the transaction that reads their orders. A route is
+-- stored with the time its inputs were read, and a replan that read them earlier
+-- doesn't overwrite it.
+ALTER TABLE couriers ADD COLUMN IF NOT EXISTS route_planned_at TIMESTAMP WITH TIME ZONE;
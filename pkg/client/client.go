@@ -0,0 +1,948 @@
+// Package client is a typed Go client for the delivery management HTTP API.
+// It mirrors the OpenAPI document served at /openapi.json. It is written by hand;
+// TestClientMatchesRoutes in internal/tests fails when a route has no method here
+// or a method calls a path the router doesn't serve.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the delivery management API. The zero value is not usable; use New.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Token is sent as a Bearer token on authenticated calls. Login sets it.
+	Token string
+	// APIKey, if set, is sent as X-API-Key instead of the Bearer token.
+	APIKey string
+}
+
+// New returns a Client for the API at baseURL (e.g. "http://localhost:8080").
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Order mirrors the Order schema.
+type Order struct {
+	ID            int        `json:"id"`
+	TenantID      int        `json:"tenant_id"`
+	CustomerID    int        `json:"customer_id"`
+	Item          string     `json:"item"`
+	Status        string     `json:"status"`
+	Pickup        *Point     `json:"pickup,omitempty"`
+	Dropoff       *Point     `json:"dropoff,omitempty"`
+	ZoneID        *int       `json:"zone_id,omitempty"`
+	DeliverAfter  *time.Time `json:"deliver_after,omitempty"`
+	DeliverBefore *time.Time `json:"deliver_before,omitempty"`
+	CourierID     *int       `json:"courier_id,omitempty"`
+	DispatchedAt  *time.Time `json:"dispatched_at,omitempty"`
+	InTransitAt   *time.Time `json:"in_transit_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	ETA           *time.Time `json:"eta"`
+	ETAConfidence float64    `json:"eta_confidence"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Point mirrors the Point schema.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// RegisterRequest mirrors the RegisterRequest schema.
+type RegisterRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Role        string `json:"role"`
+	Merchant    string `json:"merchant,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+}
+
+// RegisterResponse mirrors the RegisterResponse schema.
+type RegisterResponse struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	TenantID int    `json:"tenant_id"`
+}
+
+// Profile mirrors the Profile schema.
+type Profile struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	TenantID    int    `json:"tenant_id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+}
+
+// ProfileUpdate mirrors the ProfileUpdate schema.
+type ProfileUpdate struct {
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+}
+
+// NotificationPrefs mirrors the NotificationPrefs schema.
+type NotificationPrefs struct {
+	EmailEnabled bool   `json:"email_enabled"`
+	SMSEnabled   bool   `json:"sms_enabled"`
+	Locale       string `json:"locale"`
+	QuietStart   string `json:"quiet_start,omitempty"`
+	QuietEnd     string `json:"quiet_end,omitempty"`
+	Timezone     string `json:"timezone"`
+}
+
+// Merchant mirrors the Merchant schema.
+type Merchant struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey mirrors the APIKey schema.
+type APIKey struct {
+	ID         int        `json:"id"`
+	TenantID   int        `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *int       `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest mirrors the CreateAPIKeyRequest schema.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FieldError mirrors the FieldError schema.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is returned for any non-2xx response.
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+	}
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("api error %d: %s (%s)", e.StatusCode, e.Message, strings.Join(parts, "; "))
+}
+
+// Health calls GET /health.
+func (c *Client) Health(ctx context.Context) (string, error) {
+	var out struct {
+		Status string `json:"status"`
+	}
+	err := c.do(ctx, http.MethodGet, "/health", false, nil, &out)
+	return out.Status, err
+}
+
+// Register calls POST /register.
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*RegisterResponse, error) {
+	out := &RegisterResponse{}
+	if err := c.do(ctx, http.MethodPost, "/register", false, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MFARequiredError is returned by Login for users with two-factor authentication;
+// pass Challenge to LoginTwoFactor with a code.
+type MFARequiredError struct {
+	Challenge string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor code required"
+}
+
+// Login calls POST /login and stores the returned token on the client. For users
+// with two-factor authentication it returns an *MFARequiredError.
+func (c *Client) Login(ctx context.Context, username, password string) (string, error) {
+	req := map[string]string{"username": username, "password": password}
+	var out struct {
+		Token       string `json:"token"`
+		MFARequired bool   `json:"mfa_required"`
+		Challenge   string `json:"challenge"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/login", false, req, &out); err != nil {
+		return "", err
+	}
+	if out.MFARequired {
+		return "", &MFARequiredError{Challenge: out.Challenge}
+	}
+	c.Token = out.Token
+	return out.Token, nil
+}
+
+// LoginTwoFactor calls POST /login/2fa and stores the returned token on the client.
+func (c *Client) LoginTwoFactor(ctx context.Context, challenge, code string) (string, error) {
+	req := map[string]string{"challenge": challenge, "code": code}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/login/2fa", false, req, &out); err != nil {
+		return "", err
+	}
+	c.Token = out.Token
+	return out.Token, nil
+}
+
+// CreateOrder calls POST /api/orders.
+func (c *Client) CreateOrder(ctx context.Context, item string) (*Order, error) {
+	out := &Order{}
+	if err := c.do(ctx, http.MethodPost, "/api/orders", true, map[string]string{"item": item}, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateOrderFor calls POST /api/orders on behalf of customerID. It requires APIKey.
+func (c *Client) CreateOrderFor(ctx context.Context, customerID int, item string) (*Order, error) {
+	out := &Order{}
+	in := map[string]interface{}{"item": item, "customer_id": customerID}
+	if err := c.do(ctx, http.MethodPost, "/api/orders", true, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PlaceOrder calls POST /api/orders with the full request, positions included.
+func (c *Client) PlaceOrder(ctx context.Context, req CreateOrderRequest) (*Order, error) {
+	out := &Order{}
+	if err := c.do(ctx, http.MethodPost, "/api/orders", true, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateOrderRequest mirrors the CreateOrderRequest schema. CustomerID is only
+// used with an API key. Pickup and Dropoff are required once the merchant has
+// service zones. DeliverAfter and DeliverBefore bound the delivery window.
+type CreateOrderRequest struct {
+	Item          string     `json:"item"`
+	CustomerID    int        `json:"customer_id,omitempty"`
+	Pickup        *Point     `json:"pickup,omitempty"`
+	Dropoff       *Point     `json:"dropoff,omitempty"`
+	DeliverAfter  *time.Time `json:"deliver_after,omitempty"`
+	DeliverBefore *time.Time `json:"deliver_before,omitempty"`
+}
+
+// BatchResult mirrors the BatchResult schema.
+type BatchResult struct {
+	Row    int          `json:"row"`
+	Status string       `json:"status"`
+	Order  *Order       `json:"order,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// BatchReport mirrors the BatchReport schema.
+type BatchReport struct {
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []BatchResult `json:"results"`
+}
+
+// BatchCreateOrders calls POST /api/orders/batch with a JSON array.
+func (c *Client) BatchCreateOrders(ctx context.Context, reqs []CreateOrderRequest) (*BatchReport, error) {
+	out := &BatchReport{}
+	if err := c.do(ctx, http.MethodPost, "/api/orders/batch", true, reqs, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BatchCreateOrdersCSV calls POST /api/orders/batch with CSV read from r. The
+// first line must be a header naming the item and, optionally, customer_id columns.
+func (c *Client) BatchCreateOrdersCSV(ctx context.Context, r io.Reader) (*BatchReport, error) {
+	out := &BatchReport{}
+	if err := c.send(ctx, http.MethodPost, "/api/orders/batch", true, "text/csv", r, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListOrders calls GET /api/orders.
+func (c *Client) ListOrders(ctx context.Context) ([]Order, error) {
+	var out []Order
+	err := c.do(ctx, http.MethodGet, "/api/orders", true, nil, &out)
+	return out, err
+}
+
+// CancelOrder calls POST /api/orders/{id}/cancel and returns the resulting status.
+func (c *Client) CancelOrder(ctx context.Context, id int) (string, error) {
+	var out struct {
+		Status string `json:"status"`
+	}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/orders/%d/cancel", id), true, nil, &out)
+	return out.Status, err
+}
+
+// RequestPasswordReset calls POST /password-reset. It succeeds whether or not the
+// user exists.
+func (c *Client) RequestPasswordReset(ctx context.Context, username string) error {
+	return c.do(ctx, http.MethodPost, "/password-reset", false, map[string]string{"username": username}, nil)
+}
+
+// ConfirmPasswordReset calls POST /password-reset/confirm.
+func (c *Client) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	req := map[string]string{"token": token, "new_password": newPassword}
+	return c.do(ctx, http.MethodPost, "/password-reset/confirm", false, req, nil)
+}
+
+// Profile calls GET /api/me.
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	out := &Profile{}
+	if err := c.do(ctx, http.MethodGet, "/api/me", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateProfile calls PUT /api/me.
+func (c *Client) UpdateProfile(ctx context.Context, p ProfileUpdate) (*Profile, error) {
+	out := &Profile{}
+	if err := c.do(ctx, http.MethodPut, "/api/me", true, p, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChangePassword calls POST /api/me/password and stores the returned token on the
+// client; the server logs out every older token.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	req := map[string]string{"current_password": currentPassword, "new_password": newPassword}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/me/password", true, req, &out); err != nil {
+		return err
+	}
+	c.Token = out.Token
+	return nil
+}
+
+// DeleteAccount calls DELETE /api/me. code is a two-factor code, required if the
+// user has two-factor enabled. The client's token stops working.
+func (c *Client) DeleteAccount(ctx context.Context, password, code string) error {
+	req := map[string]string{"password": password}
+	if code != "" {
+		req["code"] = code
+	}
+	if err := c.do(ctx, http.MethodDelete, "/api/me", true, req, nil); err != nil {
+		return err
+	}
+	c.Token = ""
+	return nil
+}
+
+// AccountExport mirrors the AccountExport schema.
+type AccountExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Account    struct {
+		ID          int        `json:"id"`
+		TenantID    int        `json:"tenant_id"`
+		Username    string     `json:"username"`
+		Role        string     `json:"role"`
+		DisplayName string     `json:"display_name"`
+		Email       string     `json:"email"`
+		Phone       string     `json:"phone"`
+		CreatedAt   time.Time  `json:"created_at"`
+		LastLoginAt *time.Time `json:"last_login_at"`
+		DisabledAt  *time.Time `json:"disabled_at"`
+		DeletedAt   *time.Time `json:"deleted_at"`
+	} `json:"account"`
+	NotificationPrefs NotificationPrefs `json:"notification_preferences"`
+	TwoFactor         TwoFactorStatus   `json:"two_factor"`
+	Orders            []Order           `json:"orders"`
+	StatusChanges     []StatusChange    `json:"status_changes"`
+	Courier           *Courier          `json:"courier"`
+}
+
+// ExportAccount calls GET /api/me/export.
+func (c *Client) ExportAccount(ctx context.Context) (*AccountExport, error) {
+	out := &AccountExport{}
+	if err := c.do(ctx, http.MethodGet, "/api/me/export", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TwoFactorStatus mirrors the TwoFactorStatus schema.
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorEnrollment mirrors the TwoFactorEnrollment schema.
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactor calls GET /api/me/2fa.
+func (c *Client) TwoFactor(ctx context.Context) (*TwoFactorStatus, error) {
+	out := &TwoFactorStatus{}
+	if err := c.do(ctx, http.MethodGet, "/api/me/2fa", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EnrollTwoFactor calls POST /api/me/2fa/enroll.
+func (c *Client) EnrollTwoFactor(ctx context.Context) (*TwoFactorEnrollment, error) {
+	out := &TwoFactorEnrollment{}
+	if err := c.do(ctx, http.MethodPost, "/api/me/2fa/enroll", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConfirmTwoFactor calls POST /api/me/2fa/confirm, stores the returned token on
+// the client and returns the recovery codes.
+func (c *Client) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	var out struct {
+		Token         string   `json:"token"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/me/2fa/confirm", true, map[string]string{"code": code}, &out); err != nil {
+		return nil, err
+	}
+	c.Token = out.Token
+	return out.RecoveryCodes, nil
+}
+
+// RegenerateRecoveryCodes calls POST /api/me/2fa/recovery-codes.
+func (c *Client) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	var out struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/me/2fa/recovery-codes", true, map[string]string{"code": code}, &out); err != nil {
+		return nil, err
+	}
+	return out.RecoveryCodes, nil
+}
+
+// DisableTwoFactor calls POST /api/me/2fa/disable and stores the returned token on
+// the client.
+func (c *Client) DisableTwoFactor(ctx context.Context, code string) error {
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/me/2fa/disable", true, map[string]string{"code": code}, &out); err != nil {
+		return err
+	}
+	c.Token = out.Token
+	return nil
+}
+
+// NotificationPrefs calls GET /api/me/notifications.
+func (c *Client) NotificationPrefs(ctx context.Context) (*NotificationPrefs, error) {
+	out := &NotificationPrefs{}
+	if err := c.do(ctx, http.MethodGet, "/api/me/notifications", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetNotificationPrefs calls PUT /api/me/notifications.
+func (c *Client) SetNotificationPrefs(ctx context.Context, p NotificationPrefs) (*NotificationPrefs, error) {
+	out := &NotificationPrefs{}
+	if err := c.do(ctx, http.MethodPut, "/api/me/notifications", true, p, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminListOrders calls GET /api/admin/orders.
+func (c *Client) AdminListOrders(ctx context.Context) ([]Order, error) {
+	var out []Order
+	err := c.do(ctx, http.MethodGet, "/api/admin/orders", true, nil, &out)
+	return out, err
+}
+
+// OrderFilter holds the admin listing filters; zero fields are not sent.
+type OrderFilter struct {
+	Status     string
+	CustomerID int
+	ZoneID     int
+	From       time.Time
+	To         time.Time
+}
+
+func (f OrderFilter) query() url.Values {
+	q := url.Values{}
+	if f.Status != "" {
+		q.Set("status", f.Status)
+	}
+	if f.CustomerID != 0 {
+		q.Set("customer_id", strconv.Itoa(f.CustomerID))
+	}
+	if f.ZoneID != 0 {
+		q.Set("zone_id", strconv.Itoa(f.ZoneID))
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339))
+	}
+	return q
+}
+
+// SearchOrders calls GET /api/admin/orders with filters.
+func (c *Client) SearchOrders(ctx context.Context, f OrderFilter) ([]Order, error) {
+	var out []Order
+	err := c.do(ctx, http.MethodGet, "/api/admin/orders?"+f.query().Encode(), true, nil, &out)
+	return out, err
+}
+
+// ExportOrders calls GET /api/admin/orders/export and returns the streamed body,
+// CSV or NDJSON depending on format. The caller must close it.
+func (c *Client) ExportOrders(ctx context.Context, format string, f OrderFilter) (io.ReadCloser, error) {
+	q := f.query()
+	q.Set("format", format)
+	resp, err := c.request(ctx, http.MethodGet, "/api/admin/orders/export?"+q.Encode(), true, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// AdminUser mirrors the AdminUser schema.
+type AdminUser struct {
+	ID          int        `json:"id"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role"`
+	TenantID    int        `json:"tenant_id"`
+	DisplayName string     `json:"display_name"`
+	Email       string     `json:"email"`
+	Phone       string     `json:"phone"`
+	TwoFactor   bool       `json:"two_factor"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+// UserPage mirrors the UserPage schema.
+type UserPage struct {
+	Users     []AdminUser `json:"users"`
+	NextAfter *int        `json:"next_after"`
+}
+
+// UserFilter holds the admin user listing filters; zero fields are not sent.
+type UserFilter struct {
+	Query    string
+	Role     string
+	Disabled *bool
+	Limit    int
+	// After is the previous page's NextAfter
+	After int
+}
+
+func (f UserFilter) query() url.Values {
+	q := url.Values{}
+	if f.Query != "" {
+		q.Set("q", f.Query)
+	}
+	if f.Role != "" {
+		q.Set("role", f.Role)
+	}
+	if f.Disabled != nil {
+		q.Set("disabled", strconv.FormatBool(*f.Disabled))
+	}
+	if f.Limit != 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.After != 0 {
+		q.Set("after", strconv.Itoa(f.After))
+	}
+	return q
+}
+
+// ListUsers calls GET /api/admin/users.
+func (c *Client) ListUsers(ctx context.Context, f UserFilter) (*UserPage, error) {
+	out := &UserPage{}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/users?"+f.query().Encode(), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateUserRequest mirrors the CreateUserRequest schema.
+type CreateUserRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Role        string `json:"role"`
+	Merchant    string `json:"merchant,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+}
+
+// CreateUser calls POST /api/admin/users.
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*AdminUser, error) {
+	return c.adminUser(ctx, http.MethodPost, "/api/admin/users", req)
+}
+
+// User calls GET /api/admin/users/{id}.
+func (c *Client) User(ctx context.Context, id int) (*AdminUser, error) {
+	return c.adminUser(ctx, http.MethodGet, fmt.Sprintf("/api/admin/users/%d", id), nil)
+}
+
+// SetUserRole calls PUT /api/admin/users/{id}/role.
+func (c *Client) SetUserRole(ctx context.Context, id int, role string) (*AdminUser, error) {
+	return c.adminUser(ctx, http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", id), map[string]string{"role": role})
+}
+
+// DisableUser calls POST /api/admin/users/{id}/disable.
+func (c *Client) DisableUser(ctx context.Context, id int) (*AdminUser, error) {
+	return c.adminUser(ctx, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/disable", id), nil)
+}
+
+// EnableUser calls POST /api/admin/users/{id}/enable.
+func (c *Client) EnableUser(ctx context.Context, id int) (*AdminUser, error) {
+	return c.adminUser(ctx, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/enable", id), nil)
+}
+
+// LogoutUser calls POST /api/admin/users/{id}/logout.
+func (c *Client) LogoutUser(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/logout", id), true, nil, nil)
+}
+
+// DeleteUser calls DELETE /api/admin/users/{id}.
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/users/%d", id), true, nil, nil)
+}
+
+// ExportUser calls GET /api/admin/users/{id}/export.
+func (c *Client) ExportUser(ctx context.Context, id int) (*AccountExport, error) {
+	out := &AccountExport{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/users/%d/export", id), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) adminUser(ctx context.Context, method, path string, in interface{}) (*AdminUser, error) {
+	out := &AdminUser{}
+	if err := c.do(ctx, method, path, true, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetOrderStatusRequest mirrors the SetOrderStatusRequest schema.
+type SetOrderStatusRequest struct {
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+	Override bool   `json:"override,omitempty"`
+}
+
+// StatusChange mirrors the StatusChange schema.
+type StatusChange struct {
+	ID        int       `json:"id"`
+	OrderID   int       `json:"order_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason"`
+	Override  bool      `json:"override"`
+	ChangedBy int       `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetOrderStatus calls POST /api/admin/orders/{id}/status and returns the updated
+// order with the recorded change.
+func (c *Client) SetOrderStatus(ctx context.Context, id int, req SetOrderStatusRequest) (*Order, *StatusChange, error) {
+	var out struct {
+		Order  *Order        `json:"order"`
+		Change *StatusChange `json:"change"`
+	}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/orders/%d/status", id), true, req, &out); err != nil {
+		return nil, nil, err
+	}
+	return out.Order, out.Change, nil
+}
+
+// Report mirrors the Report schema; decode Data according to the report.
+type Report struct {
+	Name        string          `json:"report"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Report calls GET /api/admin/reports/{name}: status-daily, delivery-time or cancellation-rate.
+func (c *Client) Report(ctx context.Context, name string, f OrderFilter) (*Report, error) {
+	out := &Report{}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/reports/"+url.PathEscape(name)+"?"+f.query().Encode(), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateMerchant calls POST /api/admin/merchants.
+func (c *Client) CreateMerchant(ctx context.Context, slug, name string) (*Merchant, error) {
+	out := &Merchant{}
+	in := map[string]string{"slug": slug, "name": name}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/merchants", true, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateAPIKey calls POST /api/admin/api-keys and returns the plaintext key,
+// which the server does not keep.
+func (c *Client) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (string, *APIKey, error) {
+	var out struct {
+		Key    string  `json:"key"`
+		APIKey *APIKey `json:"api_key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/api-keys", true, req, &out); err != nil {
+		return "", nil, err
+	}
+	return out.Key, out.APIKey, nil
+}
+
+// ListAPIKeys calls GET /api/admin/api-keys.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var out []APIKey
+	err := c.do(ctx, http.MethodGet, "/api/admin/api-keys", true, nil, &out)
+	return out, err
+}
+
+// RevokeAPIKey calls DELETE /api/admin/api-keys/{id}.
+func (c *Client) RevokeAPIKey(ctx context.Context, id int) (*APIKey, error) {
+	out := &APIKey{}
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/api-keys/%d", id), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Zone mirrors the Zone schema.
+type Zone struct {
+	ID        int       `json:"id"`
+	TenantID  int       `json:"tenant_id"`
+	Name      string    `json:"name"`
+	Polygon   []Point   `json:"polygon"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ZoneRequest mirrors the ZoneRequest schema. A nil Active means active.
+type ZoneRequest struct {
+	Name    string  `json:"name"`
+	Polygon []Point `json:"polygon"`
+	Active  *bool   `json:"active,omitempty"`
+}
+
+// ListZones calls GET /api/admin/zones.
+func (c *Client) ListZones(ctx context.Context) ([]Zone, error) {
+	var out []Zone
+	err := c.do(ctx, http.MethodGet, "/api/admin/zones", true, nil, &out)
+	return out, err
+}
+
+// CreateZone calls POST /api/admin/zones.
+func (c *Client) CreateZone(ctx context.Context, req ZoneRequest) (*Zone, error) {
+	out := &Zone{}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/zones", true, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Zone calls GET /api/admin/zones/{id}.
+func (c *Client) Zone(ctx context.Context, id int) (*Zone, error) {
+	out := &Zone{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/zones/%d", id), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateZone calls PUT /api/admin/zones/{id}.
+func (c *Client) UpdateZone(ctx context.Context, id int, req ZoneRequest) (*Zone, error) {
+	out := &Zone{}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/admin/zones/%d", id), true, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Courier mirrors the Courier schema.
+type Courier struct {
+	UserID     int        `json:"user_id"`
+	TenantID   int        `json:"tenant_id"`
+	Username   string     `json:"username"`
+	Position   *Point     `json:"position"`
+	PositionAt *time.Time `json:"position_at"`
+	Route      *Route     `json:"route"`
+}
+
+// Route mirrors the Route schema.
+type Route struct {
+	Stops     []RouteStop `json:"stops"`
+	Distance  float64     `json:"distance_m"`
+	Finish    time.Time   `json:"finish"`
+	PlannedAt time.Time   `json:"planned_at"`
+}
+
+// RouteStop mirrors the RouteStop schema. Kind is "pickup" or "dropoff".
+type RouteStop struct {
+	OrderID  int        `json:"order_id"`
+	Kind     string     `json:"kind"`
+	Position Point      `json:"position"`
+	Earliest *time.Time `json:"earliest,omitempty"`
+	Latest   *time.Time `json:"latest,omitempty"`
+	Arrival  time.Time  `json:"arrival"`
+	Late     bool       `json:"late,omitempty"`
+}
+
+// CourierRoute calls GET /api/courier/route as the signed-in courier.
+func (c *Client) CourierRoute(ctx context.Context) (*Courier, error) {
+	out := &Courier{}
+	if err := c.do(ctx, http.MethodGet, "/api/courier/route", true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetCourierPosition calls PUT /api/courier/position.
+func (c *Client) SetCourierPosition(ctx context.Context, p Point) error {
+	return c.do(ctx, http.MethodPut, "/api/courier/position", true, p, nil)
+}
+
+// ListCouriers calls GET /api/admin/couriers.
+func (c *Client) ListCouriers(ctx context.Context) ([]Courier, error) {
+	var out []Courier
+	err := c.do(ctx, http.MethodGet, "/api/admin/couriers", true, nil, &out)
+	return out, err
+}
+
+// Courier calls GET /api/admin/couriers/{id}.
+func (c *Client) Courier(ctx context.Context, id int) (*Courier, error) {
+	out := &Courier{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/couriers/%d", id), true, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AssignCourier calls PUT /api/admin/orders/{id}/courier; a nil courierID takes
+// the order off its courier.
+func (c *Client) AssignCourier(ctx context.Context, id int, courierID *int) (*Order, error) {
+	out := &Order{}
+	req := map[string]*int{"courier_id": courierID}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/admin/orders/%d/courier", id), true, req, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, authed bool, in, out interface{}) error {
+	if in == nil {
+		return c.send(ctx, method, path, authed, "", nil, out)
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, authed, "application/json", bytes.NewReader(b), out)
+}
+
+// send makes a request with a body of the given content type and decodes a JSON reply into out.
+func (c *Client) send(ctx context.Context, method, path string, authed bool, contentType string, body io.Reader, out interface{}) error {
+	resp, err := c.request(ctx, method, path, authed, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request makes a request and returns the response if it is a 2xx; the caller closes the body.
+func (c *Client) request(ctx context.Context, method, path string, authed bool, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case authed && c.APIKey != "":
+		req.Header.Set("X-API-Key", c.APIKey)
+	case authed && c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// decodeError handles both the JSON Error schema and plain-text error bodies.
+func decodeError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var e struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		if json.Unmarshal(raw, &e) == nil && e.Error != "" {
+			apiErr.Message = e.Error
+			apiErr.Fields = e.Fields
+			return apiErr
+		}
+	}
+	apiErr.Message = strings.TrimSpace(string(raw))
+	return apiErr
+}